@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/Jeffail/tunny"
 	"github.com/stretchr/testify/require"
+	"github.com/thavlik/foldy-operator/proteinnet"
 )
 
 func listFiles(path string) ([]string, error) {
@@ -63,12 +65,17 @@ func TestConfiguredMinim(t *testing.T) {
 
 	log.Printf("Running minimization experiments with concurrency of %d", concurrency)
 
+	strictnessStr, ok := os.LookupEnv("STRICTNESS")
+	if !ok {
+		strictnessStr = defaultStrictness
+	}
+	strictness, err := proteinnet.ParseStrictness(strictnessStr)
+	require.NoError(t, err)
+
 	f, err := os.Open("/data/casp11/training_30")
 	require.NoError(t, err)
-	results := make(chan *record)
-	go func() {
-		require.NoError(t, ReadProteinNet(f, results))
-	}()
+	defer f.Close()
+	scanner := proteinnet.NewScanner(context.Background(), f, readOptions(strictness))
 
 	definitelyGood, err := os.Create("/data/definitely-good.txt")
 	require.NoError(t, err)
@@ -78,10 +85,10 @@ func TestConfiguredMinim(t *testing.T) {
 	definitelyGoodL := sync.Mutex{}
 
 	pool := tunny.NewFunc(concurrency, func(payload interface{}) interface{} {
-		r := payload.(*record)
+		r := payload.(*proteinnet.Record)
 		doneOuter := make(chan int, 1)
 		go func() {
-			suite := fmt.Sprintf("%s_%d_%s", r.pdbID, r.modelID, r.chainID)
+			suite := r.ID.String()
 			t.Run(suite, func(t *testing.T) {
 				defer func() {
 					// Notify the tunny func that this test is done
@@ -97,12 +104,12 @@ func TestConfiguredMinim(t *testing.T) {
 					}()
 					steps := 5
 					config, _ := json.Marshal(map[string]interface{}{
-						"pdb_id":   r.pdbID,
-						"model_id": r.modelID,
-						"chain_id": r.chainID,
+						"pdb_id":   r.StructureID,
+						"model_id": r.ModelID,
+						"chain_id": r.ChainID,
 						"steps":    steps,
-						"primary":  r.primary,
-						"mask":     r.mask,
+						"primary":  r.Primary,
+						"mask":     r.Mask,
 					})
 					url := fmt.Sprintf("http://%s/run", foldyOperator)
 					req, err := http.NewRequest("POST", url, bytes.NewReader(config))
@@ -128,7 +135,7 @@ func TestConfiguredMinim(t *testing.T) {
 					require.NoError(t, err)
 					require.Greater(t, info.Size(), int64(0))
 					untar(t, f.Name())
-					dirPath := fmt.Sprintf("%s_minim/", strings.ToLower(r.pdbID))
+					dirPath := fmt.Sprintf("%s_minim/", strings.ToLower(r.StructureID))
 					defer func() {
 						require.Nil(t, os.RemoveAll(dirPath))
 					}()
@@ -143,7 +150,7 @@ func TestConfiguredMinim(t *testing.T) {
 				}
 				definitelyGoodL.Lock()
 				defer definitelyGoodL.Unlock()
-				_, err = definitelyGood.Write([]byte(fmt.Sprintf("%s\n", r.pdbID)))
+				_, err = definitelyGood.Write([]byte(fmt.Sprintf("%s\n", r.StructureID)))
 				require.NoError(t, err)
 			})
 		}()
@@ -151,7 +158,9 @@ func TestConfiguredMinim(t *testing.T) {
 		return nil
 	})
 	defer pool.Close()
-	for r := range results {
-		pool.Process(r)
+	for scanner.Next() {
+		pool.Process(scanner.Record())
 	}
+	require.NoError(t, scanner.Err())
+	logInvalid(scanner)
 }
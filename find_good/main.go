@@ -1,100 +1,60 @@
 package main
 
 import (
-	"bufio"
-	"fmt"
-	"io"
+	"context"
+	"flag"
 	"log"
 	"os"
-	"strconv"
-	"strings"
+
+	"github.com/thavlik/foldy-operator/proteinnet"
 )
 
-func main() {
-	f, err := os.Open("/mnt/d/casp11/training_30")
-	if err != nil {
-		log.Fatal(err)
-	}
-	results := make(chan *record)
-	go func() {
-		if err := ReadProteinNet(f, results); err != nil {
-			log.Fatal(err)
-		}
-	}()
-	for r := range results {
-		log.Printf("PDB=%v ModelID=%v ChainID=%v PrimaryLen=%d", r.pdbID, r.modelID, r.chainID, len(r.primary))
+// defaultStrictness is how invalid records are handled when
+// neither the -strictness flag nor STRICTNESS say otherwise
+const defaultStrictness = "fail"
+
+// readOptions are the options find_good reads ProteinNet with.
+// Only PDB records carry the model and chain to simulate.
+func readOptions(strictness proteinnet.Strictness) proteinnet.ReadOptions {
+	return proteinnet.ReadOptions{
+		Cheap:      true,
+		Kinds:      []proteinnet.IDKind{proteinnet.KindPDB},
+		Strictness: strictness,
 	}
 }
 
-type record struct {
-	pdbID   string
-	modelID int
-	chainID string
-	primary string
-	mask    string
+// logInvalid logs the records skipped by a scanner
+// reading with CollectInvalid
+func logInvalid(scanner *proteinnet.Scanner) {
+	for _, err := range scanner.Invalid() {
+		log.Printf("Invalid record: %v", err)
+	}
+	if n := len(scanner.Invalid()); n > 0 {
+		log.Printf("Skipped %d invalid records", n)
+	}
 }
 
-// ReadProteinNet ...
-func ReadProteinNet(r io.Reader, results chan<- *record) error {
-	defer close(results)
-	scanner := bufio.NewScanner(r)
-	var next *record
-	for scanner.Scan() {
-		line := scanner.Text()
-		switch line {
-		case "[ID]":
-			if !scanner.Scan() {
-				return fmt.Errorf("expected ID")
-			}
-			id := scanner.Text()
-			parts := strings.Split(id, "_")
-			if len(parts) == 3 {
-				pdbID := parts[0]
-				modelIDStr := parts[1]
-				modelID, err := strconv.ParseInt(modelIDStr, 10, 64)
-				if err != nil {
-					return fmt.Errorf("failed to parse model ID '%v': %v", modelIDStr, err)
-				}
-				chainID := parts[2]
-				next = &record{
-					pdbID:   pdbID,
-					modelID: int(modelID),
-					chainID: chainID,
-				}
-			} else if len(parts) == 2 {
-				//log.Printf("Skipping ASTRAL %v", id)
-			} else {
-				log.Printf("Unknown ID format '%v'", id)
-				continue
-			}
-		case "[PRIMARY]":
-			if next != nil {
-				if !scanner.Scan() {
-					return fmt.Errorf("expected primary sequence")
-				}
-				next.primary = scanner.Text()
-			}
-		case "[MASK]":
-			if next != nil {
-				if !scanner.Scan() {
-					return fmt.Errorf("expected mask")
-				}
-				next.mask = scanner.Text()
-			}
-		case "":
-			if next != nil {
-				if got, expected := len(next.mask), len(next.primary); got != expected {
-					panic(fmt.Sprintf("mask length (got %v, expected %v)", got, expected))
-				}
-				results <- next
-				next = nil
-			}
-			continue
-		default:
-			// Skip the line
-			continue
-		}
+func main() {
+	path := flag.String("path", "/mnt/d/casp11/training_30", "ProteinNet file to read")
+	strictnessStr := flag.String("strictness", defaultStrictness, "how to handle invalid records: fail, skip or collect")
+	flag.Parse()
+	strictness, err := proteinnet.ParseStrictness(*strictnessStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := os.Open(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	scanner := proteinnet.NewScanner(context.Background(), f, readOptions(strictness))
+	for scanner.Next() {
+		r := scanner.Record()
+		log.Printf("PDB=%v ModelID=%v ChainID=%v PrimaryLen=%d", r.StructureID, r.ModelID, r.ChainID, len(r.Primary))
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
 	}
+	logInvalid(scanner)
 	log.Printf("Success")
-	return nil
 }
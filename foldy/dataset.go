@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/thavlik/foldy-operator/proteinnet"
+)
+
+// filterOptions are the flags of `foldy dataset filter`
+type filterOptions struct {
+	minLength        int
+	maxLength        int
+	maxMasked        float64
+	terminalGapsOnly bool
+	alphabet         string
+	allow            string
+	deny             string
+	kinds            string
+	sample           int
+	stratifyLength   int
+	seed             int64
+	strictness       string
+}
+
+func (o *filterOptions) register(fs *flag.FlagSet) {
+	fs.IntVar(&o.minLength, "min-length", 0, "minimum number of residues")
+	fs.IntVar(&o.maxLength, "max-length", 0, "maximum number of residues, 0 for no limit")
+	fs.Float64Var(&o.maxMasked, "max-masked", 1, "maximum fraction of residues missing from the structure")
+	fs.BoolVar(&o.terminalGapsOnly, "terminal-gaps-only", false, "only keep records whose missing residues are at the ends of the chain")
+	fs.StringVar(&o.alphabet, "alphabet", "", "only keep records made of these amino acids, e.g. "+proteinnet.AminoAcids)
+	fs.StringVar(&o.allow, "allow", "", "file of record or PDB IDs to keep, one per line")
+	fs.StringVar(&o.deny, "deny", "", "file of record or PDB IDs to drop, one per line")
+	fs.StringVar(&o.kinds, "kinds", "", "comma separated ID kinds to keep (pdb, astral, casp), default all")
+	fs.IntVar(&o.sample, "sample", 0, "number of records to sample at random, 0 keeps every record")
+	fs.IntVar(&o.stratifyLength, "stratify-length", 0, "sample up to -sample records from each length bin of this width")
+	fs.Int64Var(&o.seed, "seed", 1, "random seed for sampling")
+	fs.StringVar(&o.strictness, "strictness", "fail", "how to handle invalid records: fail, skip or collect")
+}
+
+// readIDList reads one ID per line, ignoring
+// blank lines and lines starting with #
+func readIDList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+	return ids, scanner.Err()
+}
+
+func (o *filterOptions) readOptions() (proteinnet.ReadOptions, error) {
+	// None of the filters look at the evolutionary or tertiary
+	// sections, which are written back out as they were read
+	options := proteinnet.ReadOptions{Cheap: true}
+	strictness, err := proteinnet.ParseStrictness(o.strictness)
+	if err != nil {
+		return options, err
+	}
+	options.Strictness = strictness
+	if o.kinds != "" {
+		for _, name := range strings.Split(o.kinds, ",") {
+			kind, err := proteinnet.ParseIDKind(strings.TrimSpace(name))
+			if err != nil {
+				return options, err
+			}
+			options.Kinds = append(options.Kinds, kind)
+		}
+	}
+	return options, nil
+}
+
+func (o *filterOptions) filter() (proteinnet.Filter, error) {
+	filters := []proteinnet.Filter{
+		proteinnet.LengthBetween(o.minLength, o.maxLength),
+	}
+	if o.maxMasked < 1 {
+		filters = append(filters, proteinnet.MaxMaskedFraction(o.maxMasked))
+	}
+	if o.terminalGapsOnly {
+		filters = append(filters, proteinnet.TerminalGapsOnly())
+	}
+	if o.alphabet != "" {
+		filters = append(filters, proteinnet.Alphabet(strings.ToUpper(o.alphabet)))
+	}
+	if o.allow != "" {
+		ids, err := readIDList(o.allow)
+		if err != nil {
+			return nil, fmt.Errorf("allow list: %v", err)
+		}
+		filters = append(filters, proteinnet.AllowIDs(ids))
+	}
+	if o.deny != "" {
+		ids, err := readIDList(o.deny)
+		if err != nil {
+			return nil, fmt.Errorf("deny list: %v", err)
+		}
+		filters = append(filters, proteinnet.DenyIDs(ids))
+	}
+	return proteinnet.All(filters...), nil
+}
+
+func (o *filterOptions) sampler() proteinnet.Sampler {
+	if o.sample <= 0 {
+		return nil
+	}
+	if o.stratifyLength > 0 {
+		return proteinnet.NewStratifiedSampler(o.sample, o.seed, proteinnet.LengthStratum(o.stratifyLength))
+	}
+	return proteinnet.NewRandomSampler(o.sample, o.seed)
+}
+
+// filterDataset copies the records of r that pass the
+// filters to w, returning the number written
+func filterDataset(ctx context.Context, r io.Reader, w io.Writer, o *filterOptions) (int, error) {
+	options, err := o.readOptions()
+	if err != nil {
+		return 0, err
+	}
+	keep, err := o.filter()
+	if err != nil {
+		return 0, err
+	}
+	sampler := o.sampler()
+	writer := proteinnet.NewWriter(w)
+	written := 0
+	scanner := proteinnet.NewScanner(ctx, r, options)
+	for scanner.Next() {
+		record := scanner.Record()
+		if !keep(record) {
+			continue
+		}
+		if sampler != nil {
+			sampler.Add(record)
+			continue
+		}
+		if err := writer.Write(record); err != nil {
+			return written, err
+		}
+		written++
+	}
+	if err := scanner.Err(); err != nil {
+		return written, err
+	}
+	for _, err := range scanner.Invalid() {
+		log.Printf("Invalid record: %v", err)
+	}
+	if sampler != nil {
+		for _, record := range sampler.Records() {
+			if err := writer.Write(record); err != nil {
+				return written, err
+			}
+			written++
+		}
+	}
+	return written, writer.Flush()
+}
+
+func runDatasetFilter(args []string) error {
+	fs := flag.NewFlagSet("dataset filter", flag.ExitOnError)
+	in := fs.String("in", "-", "ProteinNet file to read, - for stdin")
+	out := fs.String("out", "-", "ProteinNet file to write, - for stdout")
+	o := &filterOptions{}
+	o.register(fs)
+	fs.Parse(args)
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	if *out == "-" {
+		n, err := filterDataset(context.Background(), r, os.Stdout, o)
+		if err != nil {
+			return err
+		}
+		log.Printf("Wrote %d records", n)
+		return nil
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	n, err := filterDataset(context.Background(), r, f, o)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	// A failed close can lose the end of the dataset
+	if err := f.Close(); err != nil {
+		return err
+	}
+	log.Printf("Wrote %d records", n)
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleDataset = "../proteinnet/testdata/sample.txt"
+
+func runFilter(t *testing.T, o *filterOptions) (int, string) {
+	f, err := os.Open(sampleDataset)
+	require.NoError(t, err)
+	defer f.Close()
+	var out bytes.Buffer
+	n, err := filterDataset(context.Background(), f, &out, o)
+	require.NoError(t, err)
+	return n, out.String()
+}
+
+func TestFilterDataset(t *testing.T) {
+	defaults := func() *filterOptions {
+		return &filterOptions{maxMasked: 1, strictness: "fail", seed: 1}
+	}
+
+	// Records are read cheaply, yet still
+	// written with every section intact
+	options, err := defaults().readOptions()
+	require.NoError(t, err)
+	assert.True(t, options.Cheap)
+
+	// No filters copies the dataset unchanged
+	expected, err := ioutil.ReadFile(sampleDataset)
+	require.NoError(t, err)
+	n, out := runFilter(t, defaults())
+	assert.Equal(t, 3, n)
+	assert.Equal(t, string(expected), out)
+
+	o := defaults()
+	o.minLength = 20
+	n, out = runFilter(t, o)
+	assert.Equal(t, 1, n)
+	assert.True(t, strings.HasPrefix(out, "[ID]\n2L0E_1_A\n"))
+
+	dir, err := ioutil.TempDir("", "foldy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	deny := filepath.Join(dir, "deny.txt")
+	require.NoError(t, ioutil.WriteFile(deny, []byte("# known bad\n2l0e\n\n3HTY_2_B\n"), 0644))
+	o = defaults()
+	o.deny = deny
+	n, out = runFilter(t, o)
+	assert.Equal(t, 1, n)
+	assert.Contains(t, out, "1D2S_1_A")
+
+	o = defaults()
+	o.sample = 2
+	n, _ = runFilter(t, o)
+	assert.Equal(t, 2, n)
+
+	o = defaults()
+	o.kinds = "casp"
+	n, out = runFilter(t, o)
+	assert.Equal(t, 0, n)
+	assert.Empty(t, out)
+
+	o = defaults()
+	o.kinds = "pdb,bogus"
+	_, err = filterDataset(context.Background(), strings.NewReader(""), ioutil.Discard, o)
+	assert.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+const usage = `usage: foldy <command> [arguments]
+
+commands:
+  dataset filter    filter and sample a ProteinNet file
+  render frames     render a trajectory to PNG frames
+  render video      render a trajectory to an MP4, WebM or GIF
+  render compare    render trajectories superposed or side by side
+`
+
+// command runs a subcommand with the arguments following its name
+type command func(args []string) error
+
+func commands() map[string]map[string]command {
+	return map[string]map[string]command{
+		"dataset": {
+			"filter": runDatasetFilter,
+		},
+		"render": {
+			"frames":  runRenderFrames,
+			"video":   runRenderVideo,
+			"compare": runRenderCompare,
+		},
+	}
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, ok := commands()[os.Args[1]][os.Args[2]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command '%s %s'\n\n%s", os.Args[1], os.Args[2], usage)
+		os.Exit(2)
+	}
+	if err := cmd(os.Args[3:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/thavlik/foldy-operator/proteinnet"
+	"github.com/thavlik/foldy-operator/render"
+)
+
+// renderOptions are the flags shared by the render commands
+type renderOptions struct {
+	size       int
+	scale      int
+	background string
+	ambient    string
+	diffuse    string
+	align      bool
+	smoothing  float64
+	mask       string
+	gapColor   string
+	legend     bool
+	color      string
+	gradient   string
+	colorMin   float64
+	colorMax   float64
+	reference  string
+	secondary  string
+	workers    int
+	memory     int
+	id         string
+	showStep   bool
+	dt         float64
+	showRMSD   bool
+	energy     string
+	sparkline  bool
+	config     string
+}
+
+func (o *renderOptions) register(fs *flag.FlagSet) {
+	defaults := render.DefaultOptions()
+	fs.IntVar(&o.size, "size", defaults.Size, "height of each frame in pixels")
+	fs.IntVar(&o.scale, "scale", defaults.Scale, "supersampling factor")
+	fs.StringVar(&o.background, "background", "", "background color as RRGGBB (default 1D181F)")
+	fs.StringVar(&o.ambient, "ambient", "", "ambient light color as RRGGBB (default 30% gray)")
+	fs.StringVar(&o.diffuse, "diffuse", "", "diffuse light color as RRGGBB (default 90% gray)")
+	fs.BoolVar(&o.align, "align", defaults.Align, "superpose the alpha carbons of every frame onto the first")
+	fs.Float64Var(&o.smoothing, "smoothing", defaults.Smoothing, "weight of previous frames when smoothing atom positions, from 0 up to 1")
+	fs.StringVar(&o.mask, "mask", "", "ProteinNet mask of the chain, to highlight missing residues")
+	fs.StringVar(&o.gapColor, "gap-color", "", "color of residues next to missing ones as RRGGBB (default FF4F3F)")
+	fs.BoolVar(&o.legend, "legend", false, "explain the highlighting of missing residues in each frame")
+	fs.StringVar(&o.color, "color", string(render.ColorChain), "color residues by chain, hydrophobicity, type, secondary, rmsd or bfactor")
+	fs.StringVar(&o.gradient, "gradient", "", "gradient name, e.g. viridis, or comma separated RRGGBB colors (default depends on -color)")
+	fs.Float64Var(&o.colorMin, "color-min", 0, "value at the start of the gradient for rmsd and bfactor")
+	fs.Float64Var(&o.colorMax, "color-max", 0, "value at the end of the gradient for rmsd and bfactor (default 3 Å for rmsd, first frame's range for bfactor)")
+	fs.StringVar(&o.reference, "reference", "", "PDB to align frames to and measure rmsd against, instead of the first frame")
+	fs.StringVar(&o.secondary, "secondary", "", "DSSP secondary structure of each residue, for -color secondary")
+	fs.IntVar(&o.workers, "workers", 0, "number of frames drawn at once (default number of CPUs)")
+	fs.IntVar(&o.memory, "memory", 0, "memory budget for the frames being drawn in MiB, fewer workers are used if they wouldn't fit (default no limit)")
+	fs.StringVar(&o.id, "id", "", "ProteinNet ID of the structure to show on each frame, e.g. 2l0e_1_A")
+	fs.BoolVar(&o.showStep, "show-step", false, "show the step of each frame")
+	fs.Float64Var(&o.dt, "dt", 0, "simulated picoseconds between frames, to show the elapsed time")
+	fs.BoolVar(&o.showRMSD, "show-rmsd", false, "show the RMSD of each frame from the first")
+	fs.StringVar(&o.energy, "energy", "", "GROMACS .xvg of the potential energy of each step, to show on each frame")
+	fs.BoolVar(&o.sparkline, "sparkline", false, "chart the RMSD over the trajectory, marking the current frame")
+	fs.StringVar(&o.config, "config", "", "YAML render config with the camera path, e.g. \"camera: {mode: orbit}\"")
+}
+
+// parseColorFlag replaces dst with the color in
+// value, leaving the default if the flag is unset
+func parseColorFlag(name, value string, dst *fauxgl.Color) error {
+	if value == "" {
+		return nil
+	}
+	c, err := render.ParseColor(value)
+	if err != nil {
+		return fmt.Errorf("-%s: %v", name, err)
+	}
+	*dst = c
+	return nil
+}
+
+func (o *renderOptions) options() (render.Options, error) {
+	options := render.DefaultOptions()
+	options.Size = o.size
+	options.Scale = o.scale
+	options.Align = o.align
+	options.Smoothing = o.smoothing
+	options.Mask = o.mask
+	options.Legend = o.legend
+	options.ColorMin = o.colorMin
+	options.ColorMax = o.colorMax
+	options.Secondary = o.secondary
+	options.Workers = o.workers
+	options.MemoryLimit = int64(o.memory) * 1024 * 1024
+	if o.id != "" {
+		id, err := proteinnet.ParseID(o.id)
+		if err != nil {
+			return options, fmt.Errorf("-id: %v", err)
+		}
+		options.Overlay.ID = id.String()
+	}
+	options.Overlay.Step = o.showStep
+	options.Overlay.TimeStep = o.dt
+	options.Overlay.RMSD = o.showRMSD
+	options.Overlay.Sparkline = o.sparkline
+	if o.energy != "" {
+		energies, err := render.ReadXVG(o.energy)
+		if err != nil {
+			return options, fmt.Errorf("-energy: %v", err)
+		}
+		options.Overlay.Energies = energies
+	}
+	color, err := render.ParseColorScheme(o.color)
+	if err != nil {
+		return options, fmt.Errorf("-color: %v", err)
+	}
+	options.Color = color
+	if o.gradient != "" {
+		gradient, err := render.ParseGradient(o.gradient)
+		if err != nil {
+			return options, fmt.Errorf("-gradient: %v", err)
+		}
+		options.Gradient = gradient
+	}
+	if o.reference != "" {
+		reference, err := render.ReadModel(o.reference)
+		if err != nil {
+			return options, fmt.Errorf("-reference: %v", err)
+		}
+		options.Reference = reference
+	}
+	if err := parseColorFlag("background", o.background, &options.Background); err != nil {
+		return options, err
+	}
+	if err := parseColorFlag("ambient", o.ambient, &options.Ambient); err != nil {
+		return options, err
+	}
+	if err := parseColorFlag("diffuse", o.diffuse, &options.Diffuse); err != nil {
+		return options, err
+	}
+	if err := parseColorFlag("gap-color", o.gapColor, &options.GapColor); err != nil {
+		return options, err
+	}
+	if o.config != "" {
+		config, err := render.LoadConfig(o.config)
+		if err != nil {
+			return options, fmt.Errorf("-config: %v", err)
+		}
+		config.Apply(&options)
+	}
+	return options, nil
+}
+
+func runRenderFrames(args []string) error {
+	fs := flag.NewFlagSet("render frames", flag.ExitOnError)
+	in := fs.String("in", "", "result tarball or directory of *_minim_N.pdb frames")
+	out := fs.String("out", "png", "directory to write the PNG frames to")
+	o := &renderOptions{}
+	o.register(fs)
+	fs.Parse(args)
+	if *in == "" {
+		return fmt.Errorf("missing -in")
+	}
+	options, err := o.options()
+	if err != nil {
+		return err
+	}
+	frames, cleanup, err := render.LoadFrames(*in)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if err := render.RenderFrames(frames, *out, options); err != nil {
+		return err
+	}
+	log.Printf("Rendered %d frames to %s", len(frames), *out)
+	return nil
+}
+
+// videoFlags are the flags of the commands that encode videos
+type videoFlags struct {
+	format    string
+	frameRate int
+	quality   int
+}
+
+func (v *videoFlags) register(fs *flag.FlagSet) {
+	defaults := render.DefaultVideoOptions()
+	fs.StringVar(&v.format, "format", "", "mp4, webm or gif, default from the extension of -out")
+	fs.IntVar(&v.frameRate, "framerate", defaults.FrameRate, "frames per second")
+	fs.IntVar(&v.quality, "quality", defaults.Quality, "from 1 (smallest file) to 100 (best looking)")
+}
+
+func (v *videoFlags) options(out string) (render.VideoOptions, error) {
+	video := render.VideoOptions{
+		FrameRate: v.frameRate,
+		Quality:   v.quality,
+	}
+	var err error
+	if v.format != "" {
+		video.Format, err = render.ParseFormat(v.format)
+	} else {
+		video.Format, err = render.FormatFromPath(out)
+	}
+	return video, err
+}
+
+func runRenderVideo(args []string) error {
+	fs := flag.NewFlagSet("render video", flag.ExitOnError)
+	in := fs.String("in", "", "result tarball or directory of *_minim_N.pdb frames")
+	out := fs.String("out", "", "video to write, e.g. 2l0e.mp4")
+	v := &videoFlags{}
+	v.register(fs)
+	o := &renderOptions{}
+	o.register(fs)
+	fs.Parse(args)
+	if *in == "" {
+		return fmt.Errorf("missing -in")
+	}
+	if *out == "" {
+		return fmt.Errorf("missing -out")
+	}
+	options, err := o.options()
+	if err != nil {
+		return err
+	}
+	video, err := v.options(*out)
+	if err != nil {
+		return err
+	}
+	frames, cleanup, err := render.LoadFrames(*in)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if err := render.RenderVideo(frames, *out, options, video); err != nil {
+		return err
+	}
+	log.Printf("Encoded %d frames to %s", len(frames), *out)
+	return nil
+}
+
+func runRenderCompare(args []string) error {
+	fs := flag.NewFlagSet("render compare", flag.ExitOnError)
+	in := fs.String("in", "", "comma separated result tarballs or directories of *_minim_N.pdb frames")
+	names := fs.String("names", "", "comma separated names of the trajectories, e.g. \"seed 1,seed 2\"")
+	showReference := fs.Bool("show-reference", false, "show the -reference PDB as one more trajectory")
+	mode := fs.String("mode", string(render.CompareSuperposed), "superposed or tiled")
+	columns := fs.Int("columns", 0, "tiles in each row when tiled (default all in one row)")
+	out := fs.String("out", "", "video to write, e.g. seeds.mp4, or else a directory for PNG frames")
+	v := &videoFlags{}
+	v.register(fs)
+	o := &renderOptions{}
+	o.register(fs)
+	fs.Parse(args)
+	if *in == "" {
+		return fmt.Errorf("missing -in")
+	}
+	if *out == "" {
+		return fmt.Errorf("missing -out")
+	}
+	options, err := o.options()
+	if err != nil {
+		return err
+	}
+	compare := render.CompareOptions{Columns: *columns}
+	if compare.Mode, err = render.ParseCompareMode(*mode); err != nil {
+		return err
+	}
+	paths := strings.Split(*in, ",")
+	var labels []string
+	if *names != "" {
+		labels = strings.Split(*names, ",")
+	}
+	var trajectories []render.Trajectory
+	for i, path := range paths {
+		frames, cleanup, err := render.LoadFrames(strings.TrimSpace(path))
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		t := render.Trajectory{Frames: frames}
+		if i < len(labels) {
+			t.Name = strings.TrimSpace(labels[i])
+		}
+		trajectories = append(trajectories, t)
+	}
+	if *showReference {
+		if o.reference == "" {
+			return fmt.Errorf("-show-reference needs -reference")
+		}
+		// Read again, since the shown model is moved as it is drawn
+		reference, err := render.ReadModel(o.reference)
+		if err != nil {
+			return fmt.Errorf("-reference: %v", err)
+		}
+		trajectories = append(trajectories, render.Trajectory{Name: "reference", Model: reference})
+	}
+	if _, err := render.FormatFromPath(*out); err != nil && v.format == "" {
+		if err := render.CompareFrames(trajectories, *out, options, compare); err != nil {
+			return err
+		}
+		log.Printf("Rendered %d trajectories to %s", len(trajectories), *out)
+		return nil
+	}
+	video, err := v.options(*out)
+	if err != nil {
+		return err
+	}
+	if err := render.CompareVideo(trajectories, *out, options, compare, video); err != nil {
+		return err
+	}
+	log.Printf("Encoded %d trajectories to %s", len(trajectories), *out)
+	return nil
+}
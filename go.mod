@@ -9,6 +9,7 @@ require (
 	github.com/Azure/go-autorest/autorest v0.9.6 // indirect
 	github.com/Jeffail/tunny v0.0.0-20190930221602-f13eb662a36a
 	github.com/NYTimes/gziphandler v1.1.1 // indirect
+	github.com/alicebob/miniredis/v2 v2.8.0
 	github.com/cncf/udpa/go v0.0.0-20200124205748-db4b343e48c1 // indirect
 	github.com/docker/spdystream v0.0.0-20181023171402-6480d4af844c // indirect
 	github.com/elazarl/goproxy v0.0.0-20200220113713-29f9e0ba54ea // indirect
@@ -16,7 +17,7 @@ require (
 	github.com/emicklei/go-restful v2.11.2+incompatible // indirect
 	github.com/envoyproxy/go-control-plane v0.9.4 // indirect
 	github.com/evanphx/json-patch v4.5.0+incompatible // indirect
-	github.com/fogleman/ease v0.0.0-20170301025033-8da417bf1776 // indirect
+	github.com/fogleman/ease v0.0.0-20170301025033-8da417bf1776
 	github.com/fogleman/fauxgl v0.0.0-20200301021140-265867c63064
 	github.com/fogleman/ribbon v0.0.0-20191101191537-568057efb726
 	github.com/fogleman/simplify v0.0.0-20170216171241-d32f302d5046 // indirect
@@ -41,6 +42,7 @@ require (
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
 	github.com/onsi/ginkgo v1.12.0 // indirect
 	github.com/onsi/gomega v1.9.0 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
 	github.com/prometheus/client_model v0.2.0 // indirect
 	github.com/rogpeppe/go-charset v0.0.0-20190617161244-0dc95cdf6f31 // indirect
 	github.com/rogpeppe/go-internal v1.5.2 // indirect
@@ -49,7 +51,7 @@ require (
 	github.com/thavlik/ribbon v0.0.0-20200308161121-1ab3ca6cf0e4
 	golang.org/x/crypto v0.0.0-20200221231518-2aa609cf4a9d // indirect
 	golang.org/x/exp v0.0.0-20200221183520-7c80518d1cc7 // indirect
-	golang.org/x/image v0.0.0-20200119044424-58c23975cae1 // indirect
+	golang.org/x/image v0.0.0-20200119044424-58c23975cae1
 	golang.org/x/mobile v0.0.0-20200212152714-2b26a4705d24 // indirect
 	golang.org/x/net v0.0.0-20200219183655-46282727080f // indirect
 	golang.org/x/sys v0.0.0-20200219091948-cb0a6d8edb6c // indirect
@@ -65,4 +67,5 @@ require (
 	rsc.io/sampler v1.99.99 // indirect
 	sigs.k8s.io/structured-merge-diff v1.0.2 // indirect
 	sigs.k8s.io/structured-merge-diff/v3 v3.0.0-20200207201345-333e02466f54 // indirect
+	sigs.k8s.io/yaml v1.2.0
 )
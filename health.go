@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// checkDependencies verifies that redis and the kubernetes
+// API are both reachable.
+func (s *server) checkDependencies() error {
+	if err := s.redis.Ping().Err(); err != nil {
+		return fmt.Errorf("redis: %v", err)
+	}
+	if _, err := s.clientset.Discovery().ServerVersion(); err != nil {
+		return fmt.Errorf("kubernetes: %v", err)
+	}
+	return nil
+}
+
+// handleHealthz is the liveness probe. It only reports that the
+// process is serving, since restarting every replica at once
+// while redis or the API server is down would only slow recovery.
+// Dependencies are checked by the readiness probe instead.
+func (s *server) handleHealthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}
+}
+
+// handleReadyz is the readiness probe. A replica is taken out of
+// the service while it is draining or can't reach its dependencies.
+func (s *server) handleReadyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.isDraining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(errShuttingDown.Error()))
+			return
+		}
+		if err := s.checkDependencies(); err != nil {
+			log.Printf("%v: %v", r.RequestURI, err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
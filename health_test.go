@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func probe(s *server, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestProbes(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	assert.Equal(t, http.StatusOK, probe(ts.server, "/healthz").Code)
+	assert.Equal(t, http.StatusOK, probe(ts.server, "/readyz").Code)
+
+	// Only readiness depends on redis
+	ts.mr.Close()
+	assert.Equal(t, http.StatusOK, probe(ts.server, "/healthz").Code)
+	w := probe(ts.server, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "redis")
+	require.NoError(t, ts.mr.Restart())
+	assert.Equal(t, http.StatusOK, probe(ts.server, "/readyz").Code)
+}
+
+func TestReadyzDraining(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	require.NoError(t, ts.shutdown())
+	require.True(t, ts.isDraining())
+	w := probe(ts.server, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, errShuttingDown.Error(), w.Body.String())
+	// Still alive until the process exits
+	assert.Equal(t, http.StatusOK, probe(ts.server, "/healthz").Code)
+}
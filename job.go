@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// backendPod runs each simulation as a bare pod
+	backendPod = "pod"
+
+	// backendJob runs each simulation as a batch/v1 Job
+	backendJob = "job"
+)
+
+// createExperimentJobObject wraps the experiment pod in a Job so
+// that it is rescheduled on node loss. The operator timeout is
+// enforced by the Job controller through activeDeadlineSeconds,
+// and finished jobs are garbage collected after jobTTL.
+func (s *server) createExperimentJobObject(
+	config *RunConfig,
+	correlationID string,
+) (*batchv1.Job, error) {
+	pod, err := s.createExperimentPodObject(config, correlationID)
+	if err != nil {
+		return nil, err
+	}
+	activeDeadlineSeconds := int64(s.timeout / time.Second)
+	ttlSecondsAfterFinished := int32(s.jobTTL / time.Second)
+	backoffLimit := s.jobBackoffLimit
+	templateLabels := make(map[string]string, len(pod.Labels))
+	for k, v := range pod.Labels {
+		templateLabels[k] = v
+	}
+	return &batchv1.Job{
+		ObjectMeta: pod.ObjectMeta,
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &activeDeadlineSeconds,
+			TTLSecondsAfterFinished: &ttlSecondsAfterFinished,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: templateLabels,
+				},
+				Spec: pod.Spec,
+			},
+		},
+	}, nil
+}
+
+func (s *server) startJob(sim *Simulation, correlationID string) error {
+	job, err := s.createExperimentJobObject(&sim.Spec, correlationID)
+	if err != nil {
+		return fmt.Errorf("failed to create job: %v", err)
+	}
+	job.Labels["simulation"] = sim.Name
+	job.Spec.Template.Labels["simulation"] = sim.Name
+	job.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(sim, simulationGVK),
+	}
+	if _, err := s.clientset.BatchV1().Jobs(s.namespace).Create(
+		context.TODO(),
+		job,
+		metav1.CreateOptions{},
+	); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("create job: %v", err)
+	}
+	log.Printf("Simulation %s started job %s", sim.Name, job.Name)
+	sim.Status.JobName = job.Name
+	return nil
+}
+
+func jobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		c := &job.Status.Conditions[i]
+		if c.Type == conditionType && c.Status == v1.ConditionTrue {
+			return c
+		}
+	}
+	return nil
+}
+
+// syncJob transitions sim according to its Job's conditions,
+// for simulations that have not published a result.
+func (s *server) syncJob(sim *Simulation) error {
+	job, err := s.clientset.BatchV1().Jobs(s.namespace).Get(
+		context.TODO(),
+		sim.Status.JobName,
+		metav1.GetOptions{},
+	)
+	if kerrors.IsNotFound(err) {
+		return s.failSimulation(sim, "job_missing", fmt.Sprintf("job %s not found", sim.Status.JobName))
+	} else if err != nil {
+		return fmt.Errorf("get job: %v", err)
+	}
+	if c := jobCondition(job, batchv1.JobFailed); c != nil {
+		category := "pod_failed"
+		if c.Reason == "DeadlineExceeded" {
+			category = "timeout"
+		}
+		return s.failSimulation(sim, category, fmt.Sprintf("job %s failed: %s", job.Name, c.Message))
+	}
+	if c := jobCondition(job, batchv1.JobComplete); c != nil &&
+		time.Since(c.LastTransitionTime.Time) > s.missingResultTimeout {
+		return s.failSimulation(sim, "missing_result", fmt.Sprintf("job %s completed without reporting a result", job.Name))
+	}
+	if podName := s.latestJobPod(job.Name); podName != "" && podName != sim.Status.PodName {
+		sim.Status.PodName = podName
+		return s.updateSimulationStatus(sim)
+	}
+	return nil
+}
+
+// latestJobPod returns the name of the most recently created
+// pod for the named job, or an empty string if there are none.
+func (s *server) latestJobPod(jobName string) string {
+	resp, err := s.clientset.CoreV1().Pods(s.namespace).List(
+		context.TODO(),
+		metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+		},
+	)
+	if err != nil {
+		log.Printf("Warning: failed to list pods for job %s: %v", jobName, err)
+		return ""
+	}
+	var latest *v1.Pod
+	for i := range resp.Items {
+		pod := &resp.Items[i]
+		if latest == nil || pod.CreationTimestamp.After(latest.CreationTimestamp.Time) {
+			latest = pod
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.Name
+}
+
+// stopJob deletes the job for a finished simulation if it is still
+// running, which stops the Job controller from retrying it. Jobs
+// that have already finished are left for TTL cleanup.
+func (s *server) stopJob(name string) {
+	job, err := s.clientset.BatchV1().Jobs(s.namespace).Get(
+		context.TODO(),
+		name,
+		metav1.GetOptions{},
+	)
+	if kerrors.IsNotFound(err) {
+		return
+	} else if err != nil {
+		log.Printf("Warning: failed to get job %s: %v", name, err)
+		return
+	}
+	if jobCondition(job, batchv1.JobComplete) != nil || jobCondition(job, batchv1.JobFailed) != nil {
+		return
+	}
+	propagation := metav1.DeletePropagationBackground
+	if err := s.clientset.BatchV1().Jobs(s.namespace).Delete(
+		context.TODO(),
+		name,
+		&metav1.DeleteOptions{
+			PropagationPolicy: &propagation,
+		},
+	); err != nil {
+		log.Printf("Warning: failed to delete job: %v", err)
+	} else {
+		log.Printf("Deleted job %s", name)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestCreateExperimentJobObject(t *testing.T) {
+	s := &server{
+		image:           "thavlik/foldy-client:latest",
+		appLabel:        "foldy-sim",
+		namespace:       "default",
+		timeout:         time.Minute * 240,
+		jobBackoffLimit: 3,
+		jobTTL:          time.Hour,
+		scheduling:      &SchedulingConfig{},
+	}
+	job, err := s.createExperimentJobObject(&RunConfig{
+		PDBID:   "2l0e",
+		Steps:   100,
+		ModelID: 1,
+		ChainID: "A",
+	}, "0123456789abcdef")
+	require.NoError(t, err)
+	assert.Equal(t, "foldy-sim-2l0e-01234567", job.Name)
+	assert.Equal(t, int64(240*60), *job.Spec.ActiveDeadlineSeconds)
+	assert.Equal(t, int32(3600), *job.Spec.TTLSecondsAfterFinished)
+	assert.Equal(t, int32(3), *job.Spec.BackoffLimit)
+	assert.Equal(t, v1.RestartPolicyNever, job.Spec.Template.Spec.RestartPolicy)
+	assert.Equal(t, "foldy-sim", job.Spec.Template.Labels["app"])
+
+	// Job and template labels must not alias
+	job.Labels["simulation"] = "2l0e-abcde"
+	assert.NotContains(t, job.Spec.Template.Labels, "simulation")
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// controller is a background loop that must only run on a
+// single replica at a time. It should return once ctx is done.
+// The server's singletons are the controllers guarded by the
+// leader lease.
+type controller func(ctx context.Context)
+
+func leaderIdentity() string {
+	if name, ok := os.LookupEnv("POD_NAME"); ok {
+		return name
+	}
+	if name, err := os.Hostname(); err == nil {
+		return name
+	}
+	return "foldy-operator"
+}
+
+// runLeaderElection campaigns for the lease until ctx is done.
+// While this replica is the leader, every singleton controller
+// is running. HTTP handling is unaffected and runs on all
+// replicas regardless of leadership.
+func (s *server) runLeaderElection(ctx context.Context) {
+	identity := s.identity
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      s.leaseName,
+			Namespace: s.namespace,
+		},
+		Client: s.clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:          lock,
+			LeaseDuration: s.leaseDuration,
+			RenewDeadline: s.renewDeadline,
+			RetryPeriod:   s.retryPeriod,
+			// The singleton controllers are idempotent, so it is safe
+			// to give up the lease before they have fully stopped.
+			ReleaseOnCancel: true,
+			Name:            s.leaseName,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					log.Printf("%s acquired lease %s", identity, s.leaseName)
+					s.runSingletons(ctx)
+				},
+				OnStoppedLeading: func() {
+					log.Printf("%s released lease %s", identity, s.leaseName)
+				},
+				OnNewLeader: func(leader string) {
+					if leader != identity {
+						log.Printf("%s is the leader", leader)
+					}
+				},
+			},
+		})
+	}
+}
+
+func (s *server) runSingletons(ctx context.Context) {
+	wg := sync.WaitGroup{}
+	for name, run := range s.singletons {
+		wg.Add(1)
+		go func(name string, run controller) {
+			defer wg.Done()
+			log.Printf("Starting %s", name)
+			run(ctx)
+			log.Printf("Stopped %s", name)
+		}(name, run)
+	}
+	wg.Wait()
+}
+
+func (s *server) runPodPruner(ctx context.Context) {
+	for {
+		if err := s.prunePods(); err != nil {
+			log.Printf("Warning: failed to prune pods: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.pruneInterval):
+		}
+	}
+}
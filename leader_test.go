@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// singletonTracker records which of a server's singleton
+// controllers are running
+type singletonTracker struct {
+	l       sync.Mutex
+	running map[string]bool
+	started int
+}
+
+func trackSingletons(s *server) *singletonTracker {
+	tracker := &singletonTracker{running: make(map[string]bool)}
+	for name, run := range s.singletons {
+		name, run := name, run
+		s.singletons[name] = func(ctx context.Context) {
+			tracker.l.Lock()
+			tracker.running[name] = true
+			tracker.started++
+			tracker.l.Unlock()
+			run(ctx)
+			tracker.l.Lock()
+			tracker.running[name] = false
+			tracker.l.Unlock()
+		}
+	}
+	return tracker
+}
+
+// count returns how many controllers are running
+// and how many have ever started
+func (t *singletonTracker) count() (running int, started int) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	for _, ok := range t.running {
+		if ok {
+			running++
+		}
+	}
+	return running, t.started
+}
+
+// newTestElector returns a replica that campaigns for the lease,
+// with timings short enough for tests. It shares the lease with
+// another replica if one is given.
+func newTestElector(t *testing.T, identity string, lease *testServer) *testServer {
+	ts := newTestServer(t)
+	if lease != nil {
+		ts.clientset = lease.kube
+	}
+	ts.identity = identity
+	ts.leaseDuration = time.Second
+	ts.renewDeadline = 500 * time.Millisecond
+	ts.retryPeriod = 100 * time.Millisecond
+	ts.pruneInterval = 10 * time.Millisecond
+	ts.resyncInterval = 10 * time.Millisecond
+	return ts
+}
+
+func runElection(s *server) (context.CancelFunc, <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.runLeaderElection(ctx)
+	}()
+	return cancel, done
+}
+
+func TestLeaderElectionSingleLeader(t *testing.T) {
+	a := newTestElector(t, "replica-a", nil)
+	defer a.Close()
+	b := newTestElector(t, "replica-b", a)
+	defer b.Close()
+	trackers := []*singletonTracker{trackSingletons(a.server), trackSingletons(b.server)}
+	cancelA, doneA := runElection(a.server)
+	defer func() { cancelA(); <-doneA }()
+	cancelB, doneB := runElection(b.server)
+	defer func() { cancelB(); <-doneB }()
+
+	all := len(a.singletons)
+	leader := -1
+	require.Eventually(t, func() bool {
+		for i, tracker := range trackers {
+			if running, _ := tracker.count(); running == all {
+				leader = i
+				return true
+			}
+		}
+		return false
+	}, 3*time.Second, 10*time.Millisecond)
+	// Give the other replica a few chances to campaign
+	time.Sleep(5 * a.retryPeriod)
+	follower := trackers[1-leader]
+	running, started := follower.count()
+	assert.Zero(t, running)
+	assert.Zero(t, started)
+	running, _ = trackers[leader].count()
+	assert.Equal(t, all, running)
+
+	// The follower takes over once the leader steps down
+	cancels := []context.CancelFunc{cancelA, cancelB}
+	cancels[leader]()
+	require.Eventually(t, func() bool {
+		running, _ := follower.count()
+		return running == all
+	}, 3*time.Second, 10*time.Millisecond)
+	running, _ = trackers[leader].count()
+	assert.Zero(t, running)
+}
+
+func TestLeaderElectionLostLease(t *testing.T) {
+	ts := newTestElector(t, "replica-a", nil)
+	defer ts.Close()
+	tracker := trackSingletons(ts.server)
+	cancel, done := runElection(ts.server)
+	defer func() { cancel(); <-done }()
+	all := len(ts.singletons)
+	require.Eventually(t, func() bool {
+		running, _ := tracker.count()
+		return running == all
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// Another replica takes the lease, as it would if this one
+	// failed to renew it in time
+	leases := ts.kube.CoordinationV1().Leases(ts.namespace)
+	lease, err := leases.Get(context.Background(), ts.leaseName, metav1.GetOptions{})
+	require.NoError(t, err)
+	holder := "replica-b"
+	seconds := int32(60)
+	now := metav1.NewMicroTime(time.Now())
+	lease.Spec = coordinationv1.LeaseSpec{
+		HolderIdentity:       &holder,
+		LeaseDurationSeconds: &seconds,
+		AcquireTime:          &now,
+		RenewTime:            &now,
+	}
+	_, err = leases.Update(context.Background(), lease, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		running, _ := tracker.count()
+		return running == 0
+	}, 3*time.Second, 10*time.Millisecond)
+	// and they don't start again while the other replica leads
+	time.Sleep(5 * ts.retryPeriod)
+	running, started := tracker.count()
+	assert.Zero(t, running)
+	assert.Equal(t, all, started)
+}
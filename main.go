@@ -3,22 +3,26 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-redis/redis/v7"
-	"github.com/google/uuid"
 	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -32,15 +36,18 @@ type RunConfig struct {
 	Primary string `json:"primary"`
 	Mask    string `json:"mask"`
 	Seed    int    `json:"seed"`
+
+	Scheduling *SchedulingHints `json:"scheduling,omitempty"`
 }
 
 type server struct {
 	image                 string
 	appLabel              string
-	clientset             *kubernetes.Clientset
+	clientset             kubernetes.Interface
+	simulations           dynamic.ResourceInterface
 	namespace             string
 	foldyOperatorAddress  string
-	requests              map[string]chan<- interface{}
+	requests              map[string]chan<- struct{}
 	requestsL             sync.Mutex
 	timeout               time.Duration
 	handler               *http.ServeMux
@@ -48,6 +55,31 @@ type server struct {
 	exit                  chan<- error
 	multipartUploadMemory int64
 	pruneResultTimeout    time.Duration
+	httpServer            *http.Server
+	pubsub                *redis.PubSub
+	runs                  sync.WaitGroup
+	runsL                 sync.Mutex
+	draining              bool
+	handoff               chan struct{}
+	drainTimeout          time.Duration
+	shutdownTimeout       time.Duration
+	resyncInterval        time.Duration
+	pollInterval          time.Duration
+	reconcileNow          chan struct{}
+	backend               string
+	jobBackoffLimit       int32
+	jobTTL                time.Duration
+	simulationTTL         time.Duration
+	missingResultTimeout  time.Duration
+	leaseName             string
+	identity              string
+	leaseDuration         time.Duration
+	renewDeadline         time.Duration
+	retryPeriod           time.Duration
+	singletons            map[string]controller
+	pruneInterval         time.Duration
+	pruneFinishedPodAge   time.Duration
+	scheduling            *SchedulingConfig
 }
 
 func homeDir() string {
@@ -61,7 +93,7 @@ func (s *server) createExperimentPodObject(
 	config *RunConfig,
 	correlationID string,
 ) (*v1.Pod, error) {
-	return &v1.Pod{
+	pod := &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("%s-%s-%s", s.appLabel, config.PDBID, correlationID[:8]),
 			Namespace: s.namespace,
@@ -125,52 +157,107 @@ func (s *server) createExperimentPodObject(
 				},
 			},
 		},
-	}, nil
+	}
+	if err := s.applyScheduling(&pod.Spec, config); err != nil {
+		return nil, fmt.Errorf("scheduling: %v", err)
+	}
+	return pod, nil
 }
 
-func (s *server) runExperiment(config *RunConfig) ([]byte, error) {
-	correlationID := uuid.New().String()
-	log.Printf("Running experiment %s, correlationID=%s", config.PDBID, correlationID)
-	pod, err := s.createExperimentPodObject(config, correlationID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create pod: %v", err)
-	}
-	log.Printf("Pod object created. Creating pod...")
-	if _, err := s.clientset.CoreV1().Pods(s.namespace).Create(
+func (s *server) deletePod(name string) {
+	if err := s.clientset.CoreV1().Pods(s.namespace).Delete(
 		context.TODO(),
-		pod,
-		metav1.CreateOptions{},
+		name,
+		&metav1.DeleteOptions{},
 	); err != nil {
-		return nil, fmt.Errorf("create pod: %v", err)
+		log.Printf("Warning: failed to delete pod: %v", err)
+	} else {
+		log.Printf("Deleted pod %s", name)
 	}
-	defer func() {
-		// Clean up pod at the end
-		if err := s.clientset.CoreV1().Pods(s.namespace).Delete(
-			context.TODO(),
-			pod.Name,
-			&metav1.DeleteOptions{},
-		); err != nil {
-			log.Printf("Warning: failed to delete pod: %v", err)
-		} else {
-			log.Printf("Deleted pod %s", pod.Name)
-		}
-	}()
-	log.Printf("Pod created.")
-	req := make(chan interface{}, 1)
+}
+
+// normalizeRunConfig validates config and fills in defaults
+func normalizeRunConfig(config *RunConfig) error {
+	// Normalize ID as lowercase
+	config.PDBID = strings.ToLower(config.PDBID)
+	if config.Steps < 2 {
+		// Run a simulation for less than two steps?
+		return fmt.Errorf("expected >1 steps, got %d", config.Steps)
+	}
+	if config.ChainID == "" {
+		return fmt.Errorf("missing chain_id")
+	}
+	if config.Seed < -1 {
+		return fmt.Errorf("invalid seed")
+	} else if config.Seed == 0 {
+		// Default seed to -1, which is random
+		config.Seed = -1
+	}
+	return nil
+}
+
+// validateRunConfig normalizes config and checks its scheduling
+// hints against the operator's scheduling config.
+func (s *server) validateRunConfig(config *RunConfig) error {
+	if err := normalizeRunConfig(config); err != nil {
+		return err
+	}
+	if _, err := s.scheduling.resolve(config); err != nil {
+		return err
+	}
+	return nil
+}
+
+// runExperiment creates a Simulation for config and waits for
+// it to finish. The simulation controller, which may be running
+// on another replica, is responsible for the pod.
+func (s *server) runExperiment(config *RunConfig) ([]byte, error) {
+	sim, err := s.createSimulation(config)
+	if err != nil {
+		return nil, fmt.Errorf("create simulation: %v", err)
+	}
+	correlationID := string(sim.UID)
+	log.Printf("Running experiment %s, simulation=%s", config.PDBID, sim.Name)
+	req := make(chan struct{}, 1)
 	s.requestsL.Lock()
 	s.requests[correlationID] = req
 	s.requestsL.Unlock()
-	select {
-	case result := <-req:
-		if err, ok := result.(error); ok && err != nil {
+	defer func() {
+		s.requestsL.Lock()
+		delete(s.requests, correlationID)
+		s.requestsL.Unlock()
+	}()
+	// Wake the simulation controller, wherever it is
+	if err := s.redis.Publish("foldy", correlationID).Err(); err != nil {
+		log.Printf("Warning: failed to publish %s: %v", correlationID, err)
+	}
+	timeout := time.After(s.timeout)
+	for {
+		payload, err := s.getResult(correlationID)
+		if err != nil {
 			return nil, err
 		}
-		if body, ok := result.([]byte); ok {
-			return body, nil
+		if payload != nil {
+			if !payload.Success {
+				return nil, errors.New(payload.ErrorMsg)
+			}
+			return payload.Data, nil
+		}
+		sim, err = s.getSimulation(sim.Name)
+		if err != nil {
+			return nil, fmt.Errorf("get simulation: %v", err)
+		}
+		if sim.Status.Phase == SimulationFailed {
+			return nil, errors.New(sim.Status.Message)
+		}
+		select {
+		case <-req:
+		case <-time.After(s.pollInterval):
+		case <-s.handoff:
+			return nil, &handoffError{name: sim.Name}
+		case <-timeout:
+			return nil, fmt.Errorf("timed out after %v", s.timeout)
 		}
-		return nil, fmt.Errorf("malformed response from channel %T(%v)", result, result)
-	case <-time.After(s.timeout):
-		return nil, fmt.Errorf("timed out after %v", s.timeout)
 	}
 }
 
@@ -193,8 +280,10 @@ func newServer() (*server, error) {
 	if err != nil {
 		return nil, fmt.Errorf("clientset: %v", err)
 	}
-	handler := http.NewServeMux()
-
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("dynamic client: %v", err)
+	}
 	var redisURI string
 	var ok bool
 	if redisURI, ok = os.LookupEnv("REDIS_URI"); !ok {
@@ -208,12 +297,39 @@ func newServer() (*server, error) {
 	if _, err := client.Ping().Result(); err != nil {
 		return nil, fmt.Errorf("redis: %v", err)
 	}
+	s, err := newServerWithClients(clientset, dynamicClient, client)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := os.LookupEnv("SIMULATION_BACKEND"); ok {
+		switch v {
+		case backendPod, backendJob:
+			s.backend = v
+		default:
+			return nil, fmt.Errorf("unknown SIMULATION_BACKEND '%s'", v)
+		}
+	}
+	if path, ok := os.LookupEnv("SCHEDULING_CONFIG"); ok {
+		if s.scheduling, err = loadSchedulingConfig(path); err != nil {
+			return nil, fmt.Errorf("scheduling config: %v", err)
+		}
+	}
+	return s, nil
+}
 
+// newServerWithClients returns a server with the default settings
+// that talks to kubernetes and redis through the given clients
+func newServerWithClients(
+	clientset kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	client *redis.Client,
+) (*server, error) {
 	pubsub := client.Subscribe("foldy")
 	// Wait for confirmation that subscription is created before publishing anything.
 	if _, err := pubsub.Receive(); err != nil {
 		return nil, fmt.Errorf("pubsub: %v", err)
 	}
+	handler := http.NewServeMux()
 	exit := make(chan error, 1)
 	s := &server{
 		namespace:             "default",
@@ -221,56 +337,61 @@ func newServer() (*server, error) {
 		appLabel:              "foldy-sim",
 		foldyOperatorAddress:  "foldy-operator:8090",
 		clientset:             clientset,
-		requests:              make(map[string]chan<- interface{}),
+		requests:              make(map[string]chan<- struct{}),
 		timeout:               time.Minute * 240,
 		handler:               handler,
 		redis:                 client,
 		exit:                  exit,
 		multipartUploadMemory: 1024 * 1024, // 1mb
 		pruneResultTimeout:    time.Minute,
+		httpServer: &http.Server{
+			Addr:    ":8090",
+			Handler: handler,
+		},
+		pubsub:               pubsub,
+		handoff:              make(chan struct{}),
+		drainTimeout:         time.Second * 90,
+		shutdownTimeout:      time.Second * 15,
+		resyncInterval:       time.Second * 5,
+		pollInterval:         time.Second * 5,
+		reconcileNow:         make(chan struct{}, 1),
+		backend:              backendPod,
+		jobBackoffLimit:      3,
+		jobTTL:               time.Hour,
+		simulationTTL:        time.Hour * 24,
+		missingResultTimeout: time.Minute,
+		leaseName:            "foldy-operator-leader",
+		identity:             leaderIdentity(),
+		leaseDuration:        15 * time.Second,
+		renewDeadline:        10 * time.Second,
+		retryPeriod:          2 * time.Second,
+		pruneInterval:        time.Minute * 5,
+		pruneFinishedPodAge:  time.Minute * 30,
+		scheduling:           &SchedulingConfig{},
+	}
+	s.simulations = dynamicClient.Resource(simulationGVR).Namespace(s.namespace)
+	s.singletons = map[string]controller{
+		"pod-pruner":            s.runPodPruner,
+		"simulation-controller": s.runSimulationController,
 	}
 	go s.listenForPubSub(pubsub.Channel(), exit)
 	s.buildRoutes()
 	return s, nil
 }
 
-func (s *server) handleBroadcastPayload(correlationID string) error {
+// handleBroadcastPayload wakes anything on this replica that is
+// waiting on correlationID. The result itself stays in redis.
+func (s *server) handleBroadcastPayload(correlationID string) {
+	s.triggerReconcile()
 	s.requestsL.Lock()
 	req, ok := s.requests[correlationID]
-	if !ok {
-		s.requestsL.Unlock()
-		return errRequestNotFound
-	}
-	delete(s.requests, correlationID)
 	s.requestsL.Unlock()
-	defer close(req)
-
-	key := rkResult(correlationID)
-	p := s.redis.Pipeline()
-	getCmd := p.Get(key)
-	p.Del(key)
-	if _, err := p.Exec(); err != nil {
-		log.Printf("Error retrieving result: %v", err)
-		req <- fmt.Errorf("redis: %v", err)
-		return fmt.Errorf("redis: %v", err)
-	}
-	data, _ := getCmd.Result()
-	payload := &BroadcastPayload{}
-	if err := json.Unmarshal(
-		[]byte(data),
-		payload,
-	); err != nil {
-		req <- fmt.Errorf("unmarshal: %v", err)
-		return fmt.Errorf("unmarshal: %v", err)
-	}
-	if payload.Success {
-		req <- []byte(payload.Data)
-		log.Printf("%s fulfilled from remote", correlationID)
-	} else {
-		req <- fmt.Errorf(payload.ErrorMsg)
-		log.Printf("%s remote error: %v", correlationID, payload.ErrorMsg)
+	if ok {
+		select {
+		case req <- struct{}{}:
+		default:
+		}
 	}
-	return nil
 }
 
 func (s *server) listenForPubSub(
@@ -281,13 +402,12 @@ func (s *server) listenForPubSub(
 		select {
 		case <-exit:
 			return
-		case msg := <-ch:
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
 			if msg.Channel == "foldy" {
-				if err := s.handleBroadcastPayload(
-					msg.Payload,
-				); err != nil && err != errRequestNotFound {
-					log.Printf("error handling broadcast payloade: %v", err)
-				}
+				s.handleBroadcastPayload(msg.Payload)
 			}
 		}
 	}
@@ -311,19 +431,6 @@ func getCorrelationIDFromRequest(r *http.Request) (string, error) {
 
 var errRequestNotFound = fmt.Errorf("request not found")
 
-func (s *server) fullfillLocalSuccess(correlationID string, data []byte) error {
-	s.requestsL.Lock()
-	defer s.requestsL.Unlock()
-	req, ok := s.requests[correlationID]
-	if !ok {
-		return errRequestNotFound
-	}
-	delete(s.requests, correlationID)
-	req <- data
-	close(req)
-	return nil
-}
-
 // BroadcastPayload ...
 type BroadcastPayload struct {
 	Data     []byte `json:"data"`
@@ -331,14 +438,14 @@ type BroadcastPayload struct {
 	ErrorMsg string `json:"error_msg"`
 }
 
-func (s *server) fullfillRemoteError(correlationID string, errorMsg string) error {
-	p := s.redis.Pipeline()
-	body, err := json.Marshal(&BroadcastPayload{
-		ErrorMsg: errorMsg,
-	})
+// publishResult stores the result for correlationID and notifies
+// every replica that it is available.
+func (s *server) publishResult(correlationID string, payload *BroadcastPayload) error {
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("marshal: %v", err)
 	}
+	p := s.redis.Pipeline()
 	p.Set(rkResult(correlationID), body, s.pruneResultTimeout)
 	p.Publish("foldy", correlationID)
 	if _, err := p.Exec(); err != nil {
@@ -347,21 +454,20 @@ func (s *server) fullfillRemoteError(correlationID string, errorMsg string) erro
 	return nil
 }
 
-func (s *server) fullfillRemoteSuccess(correlationID string, data []byte) error {
-	p := s.redis.Pipeline()
-	body, err := json.Marshal(&BroadcastPayload{
-		Data:    data,
-		Success: true,
-	})
-	if err != nil {
-		return fmt.Errorf("marshal: %v", err)
+// getResult returns the result for correlationID,
+// or nil if it has not been published yet.
+func (s *server) getResult(correlationID string) (*BroadcastPayload, error) {
+	data, err := s.redis.Get(rkResult(correlationID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("redis: %v", err)
 	}
-	p.Set(rkResult(correlationID), body, s.pruneResultTimeout)
-	p.Publish("foldy", correlationID)
-	if _, err := p.Exec(); err != nil {
-		return fmt.Errorf("redis: %v", err)
+	payload := &BroadcastPayload{}
+	if err := json.Unmarshal([]byte(data), payload); err != nil {
+		return nil, fmt.Errorf("unmarshal: %v", err)
 	}
-	return nil
+	return payload, nil
 }
 
 func (s *server) handleComplete() http.HandlerFunc {
@@ -391,15 +497,13 @@ func (s *server) handleComplete() http.HandlerFunc {
 				//defer debug.FreeOSMemory()
 
 				// Do not wait to return a response
-				if err := s.fullfillLocalSuccess(correlationID, data); err == errRequestNotFound {
-					if err := s.fullfillRemoteSuccess(correlationID, data); err != nil {
-						log.Printf("fulfillRemote: %v", err)
-					}
-					log.Printf("%s fulfilled remotely", correlationID)
-				} else if err != nil {
-					log.Printf("fulfillLocal: %v", err)
+				if err := s.publishResult(correlationID, &BroadcastPayload{
+					Data:    data,
+					Success: true,
+				}); err != nil {
+					log.Printf("publishResult: %v", err)
 				} else {
-					log.Printf("%s fulfilled locally", correlationID)
+					log.Printf("%s fulfilled", correlationID)
 				}
 			}()
 
@@ -425,27 +529,21 @@ func (s *server) handleRun() http.HandlerFunc {
 				statusCode = http.StatusBadRequest
 				return fmt.Errorf("unmarshal: %v", err)
 			}
-			// Normalize ID as lowercase
-			config.PDBID = strings.ToLower(config.PDBID)
-			if config.Steps < 2 {
-				// Run a simulation for less than two steps?
-				statusCode = http.StatusBadRequest
-				return fmt.Errorf("expected >1 steps, got %d", config.Steps)
-			}
-			if config.ChainID == "" {
+			if err := s.validateRunConfig(config); err != nil {
 				statusCode = http.StatusBadRequest
-				return fmt.Errorf("missing chain_id")
+				return err
 			}
-			if config.Seed < -1 {
-				statusCode = http.StatusBadRequest
-				return fmt.Errorf("invalid seed")
-			} else if config.Seed == 0 {
-				// Default seed to -1, which is random
-				config.Seed = -1
+			if !s.beginRun() {
+				statusCode = http.StatusServiceUnavailable
+				return errShuttingDown
 			}
+			defer s.runs.Done()
 			log.Printf("Received run request, pdb=%s, seed=%d", config.PDBID, config.Seed)
 			body, err = s.runExperiment(config)
-			if err != nil {
+			if _, ok := err.(*handoffError); ok {
+				statusCode = http.StatusServiceUnavailable
+				return err
+			} else if err != nil {
 				return err
 			}
 			filename := fmt.Sprintf("%s_minim.tar.gz", config.PDBID)
@@ -482,22 +580,11 @@ func (s *server) handleError() http.HandlerFunc {
 				return fmt.Errorf("missing correlationID")
 			}
 			log.Printf("/error %s", msg)
-			s.requestsL.Lock()
-			req, ok := s.requests[correlationID]
-			if !ok {
-				s.requestsL.Unlock()
-				if err := s.fullfillRemoteError(
-					correlationID,
-					msg,
-				); err != nil {
-					return fmt.Errorf("fulfillRemote: %v", err)
-				}
-				return nil
+			if err := s.publishResult(correlationID, &BroadcastPayload{
+				ErrorMsg: msg,
+			}); err != nil {
+				return fmt.Errorf("publishResult: %v", err)
 			}
-			delete(s.requests, correlationID)
-			s.requestsL.Unlock()
-			req <- fmt.Errorf(msg)
-			close(req)
 			return nil
 		}(); err != nil {
 			log.Printf("%v: %v", r.RequestURI, err)
@@ -507,22 +594,166 @@ func (s *server) handleError() http.HandlerFunc {
 	}
 }
 
+// handleResult serves the result of a finished simulation,
+// as referenced by its status.resultLocation
+func (s *server) handleResult() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statusCode := http.StatusInternalServerError
+		if err := func() error {
+			correlationID, err := getCorrelationIDFromRequest(r)
+			if err != nil {
+				statusCode = http.StatusBadRequest
+				return err
+			}
+			payload, err := s.getResult(correlationID)
+			if err != nil {
+				return err
+			}
+			if payload == nil {
+				statusCode = http.StatusNotFound
+				return errRequestNotFound
+			}
+			if !payload.Success {
+				return errors.New(payload.ErrorMsg)
+			}
+			w.Header().Set("Content-Disposition", "attachment; filename="+correlationID+".tar.gz")
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(payload.Data)))
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Write(payload.Data)
+			return nil
+		}(); err != nil {
+			log.Printf("%v: %v", r.RequestURI, err)
+			w.WriteHeader(statusCode)
+			w.Write([]byte(err.Error()))
+		}
+	}
+}
+
+// handleSimulation creates a Simulation without waiting for it
+// (POST) or returns an existing one by name (GET)
+func (s *server) handleSimulation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statusCode := http.StatusInternalServerError
+		if err := func() error {
+			var sim *Simulation
+			switch r.Method {
+			case http.MethodGet:
+				name := r.URL.Query().Get("name")
+				if name == "" {
+					statusCode = http.StatusBadRequest
+					return fmt.Errorf("missing name")
+				}
+				var err error
+				sim, err = s.getSimulation(name)
+				if kerrors.IsNotFound(err) {
+					statusCode = http.StatusNotFound
+					return err
+				} else if err != nil {
+					return fmt.Errorf("get simulation: %v", err)
+				}
+			case http.MethodPost:
+				if s.isDraining() {
+					statusCode = http.StatusServiceUnavailable
+					return errShuttingDown
+				}
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					statusCode = http.StatusBadRequest
+					return fmt.Errorf("body: %v", err)
+				}
+				config := &RunConfig{}
+				if err := json.Unmarshal(body, config); err != nil {
+					statusCode = http.StatusBadRequest
+					return fmt.Errorf("unmarshal: %v", err)
+				}
+				if err := s.validateRunConfig(config); err != nil {
+					statusCode = http.StatusBadRequest
+					return err
+				}
+				sim, err = s.createSimulation(config)
+				if err != nil {
+					return fmt.Errorf("create simulation: %v", err)
+				}
+				w.WriteHeader(http.StatusCreated)
+			default:
+				statusCode = http.StatusMethodNotAllowed
+				return fmt.Errorf("method %s not allowed", r.Method)
+			}
+			body, err := json.Marshal(sim)
+			if err != nil {
+				return fmt.Errorf("marshal: %v", err)
+			}
+			w.Write(body)
+			return nil
+		}(); err != nil {
+			log.Printf("%v: %v", r.RequestURI, err)
+			w.WriteHeader(statusCode)
+			w.Write([]byte(err.Error()))
+		}
+	}
+}
+
 func (s *server) buildRoutes() {
 	s.handler.HandleFunc("/complete", s.handleComplete())
 	s.handler.HandleFunc("/run", s.handleRun())
 	s.handler.HandleFunc("/error", s.handleError())
+	s.handler.HandleFunc("/result", s.handleResult())
+	s.handler.HandleFunc("/simulation", s.handleSimulation())
+	s.handler.HandleFunc("/healthz", s.handleHealthz())
+	s.handler.HandleFunc("/readyz", s.handleReadyz())
 }
 
-func (s *server) listen() {
+func (s *server) listen() error {
+	errc := make(chan error, 1)
 	go func() {
 		log.Printf("Listening on 8090")
-		if err := http.ListenAndServe(":8090", s.handler); err != nil {
-			panic(fmt.Sprintf("ListenAndServe: %v", err))
+		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errc <- fmt.Errorf("ListenAndServe: %v", err)
 		}
 	}()
-	<-make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	leaderDone := make(chan struct{})
+	go func() {
+		s.runLeaderElection(ctx)
+		close(leaderDone)
+	}()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err := <-errc:
+		return err
+	case v := <-sig:
+		log.Printf("Received %v, shutting down", v)
+	}
+	// Give up the lease first so another replica can take over
+	// the singleton controllers while this one drains.
+	cancel()
+	<-leaderDone
+	return s.shutdown()
 }
 
+// podFinishedAt returns the time the last container in pod
+// terminated, or false if any container is still running.
+func podFinishedAt(pod *v1.Pod) (time.Time, bool) {
+	var finishedAt time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated == nil {
+			return time.Time{}, false
+		}
+		if t := status.State.Terminated.FinishedAt.Time; t.After(finishedAt) {
+			finishedAt = t
+		}
+	}
+	if finishedAt.IsZero() {
+		finishedAt = pod.CreationTimestamp.Time
+	}
+	return finishedAt, true
+}
+
+// prunePods deletes simulation pods that finished long ago. These
+// are normally removed by the simulation controller, but are left
+// behind if a Simulation never observed its pod finishing.
 func (s *server) prunePods() error {
 	resp, err := s.clientset.CoreV1().Pods(s.namespace).List(
 		context.TODO(),
@@ -533,10 +764,20 @@ func (s *server) prunePods() error {
 	if err != nil {
 		return fmt.Errorf("list pods: %v", err)
 	}
-	for _, pod := range resp.Items {
+	for i := range resp.Items {
+		pod := &resp.Items[i]
+		if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "Job" {
+			// Cleaned up by the job's ttlSecondsAfterFinished
+			continue
+		}
 		switch pod.Status.Phase {
-		case "Succeeded":
-		case "Error":
+		case v1.PodSucceeded, v1.PodFailed:
+			finishedAt, ok := podFinishedAt(pod)
+			if !ok || time.Since(finishedAt) < s.pruneFinishedPodAge {
+				continue
+			}
+			log.Printf("Pruning pod %s (%s)", pod.Name, pod.Status.Phase)
+			s.deletePod(pod.Name)
 		default:
 		}
 	}
@@ -548,11 +789,7 @@ func entry() error {
 	if err != nil {
 		return fmt.Errorf("constructor: %v", err)
 	}
-	if err := s.prunePods(); err != nil {
-		log.Printf("Warning: failed to prune pods: %v", err)
-	}
-	s.listen()
-	return nil
+	return s.listen()
 }
 
 func main() {
@@ -0,0 +1,108 @@
+package proteinnet
+
+import (
+	"strings"
+)
+
+// Filter reports whether a record should be kept
+type Filter func(r *Record) bool
+
+// All keeps records that pass every filter
+func All(filters ...Filter) Filter {
+	return func(r *Record) bool {
+		for _, f := range filters {
+			if !f(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// LengthBetween keeps records with at least min and at most
+// max residues. A max of zero means there is no upper limit.
+func LengthBetween(min, max int) Filter {
+	return func(r *Record) bool {
+		n := len(r.Primary)
+		return n >= min && (max == 0 || n <= max)
+	}
+}
+
+// MaxMaskedFraction keeps records where at most the given
+// fraction of residues are missing from the structure
+func MaxMaskedFraction(fraction float64) Filter {
+	return func(r *Record) bool {
+		if len(r.Mask) == 0 {
+			return false
+		}
+		masked := len(r.Mask) - r.KnownCount()
+		return float64(masked)/float64(len(r.Mask)) <= fraction
+	}
+}
+
+// TerminalGapsOnly keeps records whose missing residues are
+// all at the ends of the chain, like 2L0E, so the structure
+// has no gaps that would have to be bridged.
+func TerminalGapsOnly() Filter {
+	return func(r *Record) bool {
+		for _, gap := range r.Gaps() {
+			if gap.Kind == InternalGap {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Alphabet keeps records whose primary sequence only
+// contains the given amino acids
+func Alphabet(letters string) Filter {
+	return func(r *Record) bool {
+		for _, c := range r.Primary {
+			if !strings.ContainsRune(letters, c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// idSet matches records by their full ID or by PDB ID
+// alone, so that lists of bare PDB IDs can be used
+type idSet map[string]struct{}
+
+func newIDSet(ids []string) idSet {
+	set := make(idSet, len(ids))
+	for _, id := range ids {
+		set[strings.ToUpper(strings.TrimSpace(id))] = struct{}{}
+	}
+	return set
+}
+
+func (s idSet) contains(r *Record) bool {
+	if _, ok := s[strings.ToUpper(r.ID.String())]; ok {
+		return true
+	}
+	if r.ID.StructureID != "" {
+		if _, ok := s[strings.ToUpper(r.ID.StructureID)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowIDs keeps only the records in ids. Each entry is either
+// a full record ID or a PDB ID, and is not case sensitive.
+func AllowIDs(ids []string) Filter {
+	set := newIDSet(ids)
+	return set.contains
+}
+
+// DenyIDs drops the records in ids, which are matched
+// the same way as for AllowIDs
+func DenyIDs(ids []string) Filter {
+	set := newIDSet(ids)
+	return func(r *Record) bool {
+		return !set.contains(r)
+	}
+}
@@ -0,0 +1,40 @@
+package proteinnet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilters(t *testing.T) {
+	r := &Record{
+		ID:          ID{Kind: KindPDB, StructureID: "2L0E", ModelID: 1, ChainID: "A"},
+		StructureID: "2L0E",
+		Primary:     "AKKKDNLLFG",
+		Mask:        "-++++++++-",
+	}
+	gapped := &Record{
+		ID:      ID{Kind: KindCASP, Class: "FM", TargetID: "T0800"},
+		Primary: "AKXKDNLLFG",
+		Mask:    "+++--+++++",
+	}
+	for name, c := range map[string]struct {
+		filter   Filter
+		expected [2]bool
+	}{
+		"length":         {LengthBetween(10, 10), [2]bool{true, true}},
+		"too short":      {LengthBetween(11, 0), [2]bool{false, false}},
+		"masked":         {MaxMaskedFraction(0.2), [2]bool{true, true}},
+		"too masked":     {MaxMaskedFraction(0.1), [2]bool{false, false}},
+		"terminal gaps":  {TerminalGapsOnly(), [2]bool{true, false}},
+		"alphabet":       {Alphabet(AminoAcids), [2]bool{true, false}},
+		"allow pdb":      {AllowIDs([]string{"2l0e"}), [2]bool{true, false}},
+		"allow full id":  {AllowIDs([]string{"fm#t0800"}), [2]bool{false, true}},
+		"deny":           {DenyIDs([]string{"2L0E_1_A"}), [2]bool{false, true}},
+		"all":            {All(LengthBetween(1, 0), TerminalGapsOnly()), [2]bool{true, false}},
+		"all of nothing": {All(), [2]bool{true, true}},
+	} {
+		assert.Equal(t, c.expected[0], c.filter(r), name)
+		assert.Equal(t, c.expected[1], c.filter(gapped), name)
+	}
+}
@@ -0,0 +1,135 @@
+package proteinnet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IDKind is the kind of structure a record ID refers to
+type IDKind int
+
+const (
+	// KindPDB IDs look like 1ABC_1_A: PDB ID, model and chain
+	KindPDB IDKind = iota
+
+	// KindASTRAL IDs look like 1ABC_d1abca1: PDB ID and ASTRAL domain
+	KindASTRAL
+
+	// KindCASP IDs look like TBM#T0759: target class and CASP target
+	KindCASP
+)
+
+func (k IDKind) String() string {
+	switch k {
+	case KindPDB:
+		return "pdb"
+	case KindASTRAL:
+		return "astral"
+	case KindCASP:
+		return "casp"
+	default:
+		return fmt.Sprintf("IDKind(%d)", int(k))
+	}
+}
+
+// ID is a parsed ProteinNet record ID
+type ID struct {
+	Kind IDKind
+
+	// Thinning is the sequence identity percentage of the validation
+	// set a record belongs to, as in 70#1ABC_1_A, or 0 if unset
+	Thinning int
+
+	// StructureID is the PDB ID of PDB and ASTRAL records
+	StructureID string
+
+	// ModelID and ChainID are set for PDB records
+	ModelID int
+	ChainID string
+
+	// ASTRALID is the ASTRAL domain of ASTRAL records
+	ASTRALID string
+
+	// Class is the category of a CASP target, e.g. TBM or FM
+	Class string
+
+	// TargetID is the CASP target, e.g. T0759
+	TargetID string
+}
+
+// ParseID parses any of the ID formats used by ProteinNet
+func ParseID(s string) (ID, error) {
+	id := ID{}
+	rest := s
+	if i := strings.Index(s, "#"); i >= 0 {
+		prefix := s[:i]
+		rest = s[i+1:]
+		if prefix == "" || rest == "" {
+			return ID{}, fmt.Errorf("malformed ID format '%v'", s)
+		}
+		if thinning, err := strconv.Atoi(prefix); err == nil {
+			id.Thinning = thinning
+		} else {
+			if strings.Contains(rest, "_") {
+				return ID{}, fmt.Errorf("malformed CASP target '%v'", s)
+			}
+			id.Kind = KindCASP
+			id.Class = prefix
+			id.TargetID = rest
+			return id, nil
+		}
+	}
+	// ASTRAL domains may end in an underscore, as in d1a0aa_,
+	// so only the first underscore reliably ends the PDB ID
+	parts := strings.SplitN(rest, "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ID{}, fmt.Errorf("malformed ID format '%v'", s)
+	}
+	id.StructureID = parts[0]
+	if i := strings.Index(parts[1], "_"); i >= 0 && i < len(parts[1])-1 {
+		// <model>_<chain>
+		model, chain := parts[1][:i], parts[1][i+1:]
+		if strings.Contains(chain, "_") {
+			return ID{}, fmt.Errorf("malformed ID format '%v'", s)
+		}
+		modelID, err := strconv.ParseInt(model, 10, 64)
+		if err != nil {
+			return ID{}, fmt.Errorf("failed to parse model ID '%v': %v", model, err)
+		}
+		id.Kind = KindPDB
+		id.ModelID = int(modelID)
+		id.ChainID = chain
+	} else {
+		id.Kind = KindASTRAL
+		id.ASTRALID = parts[1]
+	}
+	return id, nil
+}
+
+// String returns the ID as it appears in ProteinNet files
+func (id ID) String() string {
+	var s string
+	switch id.Kind {
+	case KindCASP:
+		return fmt.Sprintf("%s#%s", id.Class, id.TargetID)
+	case KindASTRAL:
+		s = fmt.Sprintf("%s_%s", id.StructureID, id.ASTRALID)
+	default:
+		s = fmt.Sprintf("%s_%d_%s", id.StructureID, id.ModelID, id.ChainID)
+	}
+	if id.Thinning != 0 {
+		s = fmt.Sprintf("%d#%s", id.Thinning, s)
+	}
+	return s
+}
+
+// ParseIDKind parses the name of an IDKind, as returned by String
+func ParseIDKind(s string) (IDKind, error) {
+	for _, k := range []IDKind{KindPDB, KindASTRAL, KindCASP} {
+		if s == k.String() {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown ID kind '%s', expected pdb, astral or casp", s)
+}
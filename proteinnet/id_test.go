@@ -0,0 +1,102 @@
+package proteinnet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseID(t *testing.T) {
+	for s, expected := range map[string]ID{
+		"1ABC_1_A": {
+			Kind:        KindPDB,
+			StructureID: "1ABC",
+			ModelID:     1,
+			ChainID:     "A",
+		},
+		"1D2S_d1d2sa1": {
+			Kind:        KindASTRAL,
+			StructureID: "1D2S",
+			ASTRALID:    "d1d2sa1",
+		},
+		"70#3HTY_2_B": {
+			Kind:        KindPDB,
+			Thinning:    70,
+			StructureID: "3HTY",
+			ModelID:     2,
+			ChainID:     "B",
+		},
+		"30#1D2S_d1d2sa1": {
+			Kind:        KindASTRAL,
+			Thinning:    30,
+			StructureID: "1D2S",
+			ASTRALID:    "d1d2sa1",
+		},
+		"1A0A_d1a0aa_": {
+			Kind:        KindASTRAL,
+			StructureID: "1A0A",
+			ASTRALID:    "d1a0aa_",
+		},
+		"70#1A0A_d1a0aa_": {
+			Kind:        KindASTRAL,
+			Thinning:    70,
+			StructureID: "1A0A",
+			ASTRALID:    "d1a0aa_",
+		},
+		"1A04_d1a04a1": {
+			Kind:        KindASTRAL,
+			StructureID: "1A04",
+			ASTRALID:    "d1a04a1",
+		},
+		"TBM#T0759": {
+			Kind:     KindCASP,
+			Class:    "TBM",
+			TargetID: "T0759",
+		},
+		"TBM-hard#T0820": {
+			Kind:     KindCASP,
+			Class:    "TBM-hard",
+			TargetID: "T0820",
+		},
+	} {
+		id, err := ParseID(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, id, s)
+		assert.Equal(t, s, id.String())
+	}
+	for _, s := range []string{
+		"1ABC",
+		"1ABC_x_A",
+		"1ABC_1_A_B",
+		"1ABC_",
+		"_1_A",
+		"#1ABC_1_A",
+		"70#",
+		"FM#1ABC_1_A",
+	} {
+		_, err := ParseID(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestReadRecordsKinds(t *testing.T) {
+	records := readAll(t, "testdata/ids.txt", ReadOptions{})
+	require.Len(t, records, 4)
+	assert.Equal(t, KindPDB, records[0].ID.Kind)
+	assert.Equal(t, KindASTRAL, records[1].ID.Kind)
+	assert.Equal(t, "GHIKL", records[1].Primary)
+	assert.Equal(t, 70, records[2].ID.Thinning)
+	assert.Equal(t, "3HTY", records[2].StructureID)
+	assert.Equal(t, KindCASP, records[3].ID.Kind)
+	assert.Equal(t, "T0759", records[3].ID.TargetID)
+
+	records = readAll(t, "testdata/ids.txt", ReadOptions{
+		Kinds: []IDKind{KindPDB},
+	})
+	require.Len(t, records, 2)
+	assert.Equal(t, "1ABC_1_A", records[0].ID.String())
+	assert.Equal(t, "70#3HTY_2_B", records[1].ID.String())
+	// Sections of skipped records are not attributed to others
+	assert.Equal(t, "MNPQR", records[1].Primary)
+}
@@ -0,0 +1,317 @@
+package proteinnet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IndexSuffix is appended to the path of a ProteinNet
+// file to get the path of its index
+const IndexSuffix = ".idx"
+
+// IndexEntry is the location of a record within a file
+type IndexEntry struct {
+	ID     ID
+	Offset int64
+	Length int64
+}
+
+// Index locates every record in a ProteinNet file. It is
+// stored in a sidecar file next to the data, with a header
+// line holding the size of the data file and one line per
+// record: the ID, its byte offset and its length.
+type Index struct {
+	// Size is the size of the indexed file in bytes
+	Size    int64
+	Entries []IndexEntry
+
+	// Invalid are the errors of records left out of the index,
+	// when built with CollectInvalid. They are not written to
+	// the sidecar file.
+	Invalid []*ParseError
+
+	byID  map[string]int
+	byPDB map[pdbKey]int
+}
+
+type pdbKey struct {
+	structureID string
+	modelID     int
+	chainID     string
+}
+
+func (idx *Index) add(entry IndexEntry) {
+	if idx.byID == nil {
+		idx.byID = make(map[string]int)
+		idx.byPDB = make(map[pdbKey]int)
+	}
+	i := len(idx.Entries)
+	idx.Entries = append(idx.Entries, entry)
+	if _, ok := idx.byID[entry.ID.String()]; !ok {
+		idx.byID[entry.ID.String()] = i
+	}
+	if entry.ID.Kind == KindPDB {
+		// Validation sets can be looked up without their thinning
+		key := pdbKey{
+			structureID: strings.ToUpper(entry.ID.StructureID),
+			modelID:     entry.ID.ModelID,
+			chainID:     entry.ID.ChainID,
+		}
+		if _, ok := idx.byPDB[key]; !ok {
+			idx.byPDB[key] = i
+		}
+	}
+}
+
+// BuildIndex scans a ProteinNet file for the offsets of its
+// records. A record spans from its [ID] line to the next one.
+// Records with an ID that can't be parsed are handled according
+// to strictness, and are left out of the index unless it fails.
+func BuildIndex(r io.Reader, strictness Strictness) (*Index, error) {
+	// Lines are read in pieces, so even the longest
+	// tertiary rows do not need to fit in the buffer
+	br := bufio.NewReaderSize(r, 1024*1024)
+	idx := &Index{}
+	var offset int64
+	line := 0
+	var pending *IndexEntry
+	atLineStart := true
+	expectID := false
+	for {
+		chunk, err := br.ReadSlice('\n')
+		lineStart := atLineStart
+		atLineStart = err == nil
+		if lineStart && len(chunk) > 0 {
+			line++
+			text := string(bytes.TrimRight(chunk, "\r\n"))
+			if expectID {
+				expectID = false
+				id, err := ParseID(text)
+				if err != nil {
+					perr := &ParseError{Line: line, Err: err}
+					switch strictness {
+					case SkipInvalid:
+						log.Printf("Warning: skipping invalid record: %v", perr)
+					case CollectInvalid:
+						idx.Invalid = append(idx.Invalid, perr)
+					default:
+						return nil, perr
+					}
+					pending = nil
+				} else {
+					pending.ID = id
+				}
+			} else if text == "[ID]" {
+				if pending != nil {
+					pending.Length = offset - pending.Offset
+					idx.add(*pending)
+				}
+				pending = &IndexEntry{Offset: offset}
+				expectID = true
+			}
+		}
+		offset += int64(len(chunk))
+		if err == bufio.ErrBufferFull {
+			continue
+		} else if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if expectID {
+		return nil, fmt.Errorf("expected ID")
+	}
+	if pending != nil {
+		pending.Length = offset - pending.Offset
+		idx.add(*pending)
+	}
+	idx.Size = offset
+	return idx, nil
+}
+
+// WriteTo writes the index in its sidecar format
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	write := func(format string, args ...interface{}) error {
+		written, err := fmt.Fprintf(bw, format, args...)
+		n += int64(written)
+		return err
+	}
+	if err := write("%d\n", idx.Size); err != nil {
+		return n, err
+	}
+	for _, entry := range idx.Entries {
+		if err := write("%s\t%d\t%d\n", entry.ID, entry.Offset, entry.Length); err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// ReadIndex reads an index written by WriteTo
+func ReadIndex(r io.Reader) (*Index, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("missing index header")
+	}
+	size, err := strconv.ParseInt(scanner.Text(), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("index header: %v", err)
+	}
+	idx := &Index{Size: size}
+	for line := 2; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("index line %d: expected 3 fields, got %d", line, len(fields))
+		}
+		id, err := ParseID(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("index line %d: %v", line, err)
+		}
+		offset, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("index line %d: offset: %v", line, err)
+		}
+		length, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("index line %d: length: %v", line, err)
+		}
+		idx.add(IndexEntry{ID: id, Offset: offset, Length: length})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// BuildIndexFile indexes the ProteinNet file at path
+// and writes the index to its sidecar file.
+func BuildIndexFile(path string, strictness Strictness) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	idx, err := BuildIndex(f, strictness)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	out, err := os.Create(path + IndexSuffix)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := idx.WriteTo(out); err != nil {
+		out.Close()
+		return nil, fmt.Errorf("write index: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// IndexedFile is a ProteinNet file opened for random access
+type IndexedFile struct {
+	f       *os.File
+	Index   *Index
+	Options ReadOptions
+}
+
+// OpenIndexed opens the ProteinNet file at path using its
+// sidecar index, building the index first if there is none.
+// An index that no longer matches the size of the file is
+// rebuilt. Records are built into the index and read with
+// options.
+func OpenIndexed(path string, options ReadOptions) (*IndexedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	idx, err := openIndex(path + IndexSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		f.Close()
+		return nil, fmt.Errorf("read index: %v", err)
+	}
+	if idx == nil || idx.Size != info.Size() {
+		if idx, err = BuildIndexFile(path, options.Strictness); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return &IndexedFile{f: f, Index: idx, Options: options}, nil
+}
+
+func openIndex(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadIndex(f)
+}
+
+// Close closes the underlying file
+func (f *IndexedFile) Close() error {
+	return f.f.Close()
+}
+
+// Read reads the record at the location of entry. The record
+// is read even if Options leaves out its kind, and an invalid
+// record is an error rather than skipped.
+func (f *IndexedFile) Read(entry IndexEntry) (*Record, error) {
+	options := f.Options
+	options.Kinds = nil
+	options.Strictness = FailInvalid
+	scanner := NewScanner(
+		context.Background(),
+		io.NewSectionReader(f.f, entry.Offset, entry.Length),
+		options,
+	)
+	if !scanner.Next() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("offset %d: %v", entry.Offset, err)
+		}
+		return nil, fmt.Errorf("no record at offset %d", entry.Offset)
+	}
+	return scanner.Record(), nil
+}
+
+// Get returns the record with the given ID
+func (f *IndexedFile) Get(id ID) (*Record, error) {
+	i, ok := f.Index.byID[id.String()]
+	if !ok {
+		return nil, fmt.Errorf("record '%s' not found", id)
+	}
+	return f.Read(f.Index.Entries[i])
+}
+
+// Lookup returns the record for a chain of a PDB model. The
+// PDB ID is not case sensitive.
+func (f *IndexedFile) Lookup(pdbID string, modelID int, chainID string) (*Record, error) {
+	i, ok := f.Index.byPDB[pdbKey{
+		structureID: strings.ToUpper(pdbID),
+		modelID:     modelID,
+		chainID:     chainID,
+	}]
+	if !ok {
+		return nil, fmt.Errorf("record '%s_%d_%s' not found", pdbID, modelID, chainID)
+	}
+	return f.Read(f.Index.Entries[i])
+}
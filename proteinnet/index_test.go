@@ -0,0 +1,166 @@
+package proteinnet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// copyFixture copies a fixture to a temporary directory,
+// so its sidecar index does not end up in testdata
+func copyFixture(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "proteinnet")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+	return path
+}
+
+func TestBuildIndex(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/ids.txt")
+	require.NoError(t, err)
+	idx, err := BuildIndex(bytes.NewReader(data), FailInvalid)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), idx.Size)
+	require.Len(t, idx.Entries, 4)
+	var total int64
+	for _, entry := range idx.Entries {
+		record := string(data[entry.Offset : entry.Offset+entry.Length])
+		assert.True(t, strings.HasPrefix(record, "[ID]\n"+entry.ID.String()+"\n"), record)
+		total += entry.Length
+	}
+	assert.Equal(t, idx.Size, total)
+
+	var buf bytes.Buffer
+	_, err = idx.WriteTo(&buf)
+	require.NoError(t, err)
+	decoded, err := ReadIndex(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, idx.Size, decoded.Size)
+	assert.Equal(t, idx.Entries, decoded.Entries)
+}
+
+func TestBuildIndexLongLines(t *testing.T) {
+	// Longer than the read buffer
+	long := strings.Repeat("0.0\t", 1024*1024)
+	input := "[ID]\n1ABC_1_A\n[NOTE]\n" + long + "\n\n[ID]\n2ABC_1_A\n"
+	idx, err := BuildIndex(strings.NewReader(input), FailInvalid)
+	require.NoError(t, err)
+	require.Len(t, idx.Entries, 2)
+	assert.Equal(t, "2ABC", idx.Entries[1].ID.StructureID)
+	assert.Equal(t, int64(strings.Index(input, "[ID]\n2ABC")), idx.Entries[1].Offset)
+}
+
+func TestBuildIndexInvalidID(t *testing.T) {
+	input := "[ID]\n1ABC_1_A\n[PRIMARY]\nAC\n\n" +
+		"[ID]\n1ABC\n[PRIMARY]\nDE\n\n" +
+		"[ID]\n2ABC_1_A\n[PRIMARY]\nFG\n\n"
+	_, err := BuildIndex(strings.NewReader(input), FailInvalid)
+	require.Error(t, err)
+	perr, ok := err.(*ParseError)
+	require.True(t, ok, "expected a ParseError, got %T", err)
+	assert.Equal(t, 7, perr.Line)
+
+	for _, strictness := range []Strictness{SkipInvalid, CollectInvalid} {
+		idx, err := BuildIndex(strings.NewReader(input), strictness)
+		require.NoError(t, err)
+		require.Len(t, idx.Entries, 2)
+		assert.Equal(t, "1ABC_1_A", idx.Entries[0].ID.String())
+		assert.Equal(t, "2ABC_1_A", idx.Entries[1].ID.String())
+		// The bad record belongs to neither of its neighbours
+		first := idx.Entries[0]
+		assert.Equal(t, "[ID]\n1ABC_1_A\n[PRIMARY]\nAC\n\n", input[first.Offset:first.Offset+first.Length])
+		assert.Equal(t, int64(len(input)), idx.Entries[1].Offset+idx.Entries[1].Length)
+		if strictness == CollectInvalid {
+			require.Len(t, idx.Invalid, 1)
+			assert.Equal(t, 7, idx.Invalid[0].Line)
+		} else {
+			assert.Empty(t, idx.Invalid)
+		}
+	}
+}
+
+func TestIndexedFileLookup(t *testing.T) {
+	path := copyFixture(t, "sample.txt")
+	f, err := OpenIndexed(path, ReadOptions{})
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = os.Stat(path + IndexSuffix)
+	require.NoError(t, err)
+
+	r, err := f.Lookup("3hty", 2, "B")
+	require.NoError(t, err)
+	assert.Equal(t, "3HTY", r.StructureID)
+	require.NotNil(t, r.Tertiary)
+	assert.Len(t, r.Tertiary.CA, len(r.Primary))
+
+	_, err = f.Lookup("3hty", 1, "B")
+	assert.Error(t, err)
+
+	// Records read through the index match those read in order
+	records := readAll(t, path, ReadOptions{})
+	for _, expected := range records {
+		r, err := f.Get(expected.ID)
+		require.NoError(t, err)
+		assert.Equal(t, expected, r)
+	}
+}
+
+func TestIndexedFileThinning(t *testing.T) {
+	path := copyFixture(t, "ids.txt")
+	f, err := OpenIndexed(path, ReadOptions{})
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := f.Lookup("3HTY", 2, "B")
+	require.NoError(t, err)
+	assert.Equal(t, 70, r.ID.Thinning)
+	id, err := ParseID("TBM#T0759")
+	require.NoError(t, err)
+	r, err = f.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, "STVWY", r.Primary)
+}
+
+func TestIndexedFileKinds(t *testing.T) {
+	path := copyFixture(t, "ids.txt")
+	f, err := OpenIndexed(path, ReadOptions{Kinds: []IDKind{KindPDB}})
+	require.NoError(t, err)
+	defer f.Close()
+	// Looked up directly, records of other kinds are still read
+	id, err := ParseID("TBM#T0759")
+	require.NoError(t, err)
+	r, err := f.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, "STVWY", r.Primary)
+	r, err = f.Lookup("1abc", 1, "A")
+	require.NoError(t, err)
+	assert.Equal(t, "ACDEF", r.Primary)
+}
+
+func TestIndexedFileStale(t *testing.T) {
+	path := copyFixture(t, "ids.txt")
+	_, err := BuildIndexFile(path, FailInvalid)
+	require.NoError(t, err)
+	extra := "[ID]\n4XYZ_1_C\n[PRIMARY]\nAC\n[MASK]\n++\n\n"
+	out, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = out.WriteString(extra)
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+
+	f, err := OpenIndexed(path, ReadOptions{})
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := f.Lookup("4xyz", 1, "C")
+	require.NoError(t, err)
+	assert.Equal(t, "AC", r.Primary)
+}
@@ -0,0 +1,120 @@
+package proteinnet
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Known marks a residue present in the structure
+	Known = '+'
+
+	// Missing marks a residue absent from the structure
+	Missing = '-'
+)
+
+// GapKind is where a run of missing residues is in the chain
+type GapKind int
+
+const (
+	// NTerminalGap is at the start of the chain. A chain
+	// with no known residues is a single N-terminal gap.
+	NTerminalGap GapKind = iota
+
+	// CTerminalGap is at the end of the chain
+	CTerminalGap
+
+	// InternalGap has known residues on both sides, and
+	// breaks the backbone of the structure
+	InternalGap
+)
+
+func (k GapKind) String() string {
+	switch k {
+	case NTerminalGap:
+		return "n-terminal"
+	case CTerminalGap:
+		return "c-terminal"
+	case InternalGap:
+		return "internal"
+	default:
+		return fmt.Sprintf("GapKind(%d)", int(k))
+	}
+}
+
+// Gap is a contiguous run of missing residues,
+// from Start up to but not including End
+type Gap struct {
+	Start int
+	End   int
+	Kind  GapKind
+}
+
+// Len returns the number of missing residues
+func (g Gap) Len() int {
+	return g.End - g.Start
+}
+
+// KnownCount returns the number of residues in the structure
+func KnownCount(mask string) int {
+	return strings.Count(mask, string(Known))
+}
+
+// Gaps returns the runs of missing residues in mask, in order
+func Gaps(mask string) []Gap {
+	var gaps []Gap
+	for i := 0; i < len(mask); {
+		if mask[i] != Missing {
+			i++
+			continue
+		}
+		start := i
+		for i < len(mask) && mask[i] == Missing {
+			i++
+		}
+		kind := InternalGap
+		if start == 0 {
+			kind = NTerminalGap
+		} else if i == len(mask) {
+			kind = CTerminalGap
+		}
+		gaps = append(gaps, Gap{Start: start, End: i, Kind: kind})
+	}
+	return gaps
+}
+
+// TrimPrimary returns the residues of primary that are in the
+// structure. This is the sequence normalize_structure expects
+// to be left in the chain once missing residues are dropped.
+func TrimPrimary(primary, mask string) (string, error) {
+	if len(primary) != len(mask) {
+		return "", fmt.Errorf("mask length (got %v, expected %v)", len(mask), len(primary))
+	}
+	var b strings.Builder
+	b.Grow(KnownCount(mask))
+	for i := 0; i < len(mask); i++ {
+		switch mask[i] {
+		case Known:
+			b.WriteByte(primary[i])
+		case Missing:
+		default:
+			return "", fmt.Errorf("unexpected mask character '%c' at %d", mask[i], i)
+		}
+	}
+	return b.String(), nil
+}
+
+// KnownCount returns the number of residues in the structure
+func (r *Record) KnownCount() int {
+	return KnownCount(r.Mask)
+}
+
+// Gaps returns the runs of residues missing from the structure
+func (r *Record) Gaps() []Gap {
+	return Gaps(r.Mask)
+}
+
+// TrimmedPrimary returns the residues that are in the structure
+func (r *Record) TrimmedPrimary() (string, error) {
+	return TrimPrimary(r.Primary, r.Mask)
+}
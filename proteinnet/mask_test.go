@@ -0,0 +1,51 @@
+package proteinnet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGaps(t *testing.T) {
+	for mask, expected := range map[string][]Gap{
+		"+++++":     nil,
+		"-+++-":     {{0, 1, NTerminalGap}, {4, 5, CTerminalGap}},
+		"--++-+++-": {{0, 2, NTerminalGap}, {4, 5, InternalGap}, {8, 9, CTerminalGap}},
+		"++---++":   {{2, 5, InternalGap}},
+		"---":       {{0, 3, NTerminalGap}},
+		"":          nil,
+	} {
+		assert.Equal(t, expected, Gaps(mask), mask)
+	}
+	assert.Equal(t, 3, Gap{Start: 2, End: 5}.Len())
+	assert.Equal(t, "internal", InternalGap.String())
+}
+
+func TestKnownCount(t *testing.T) {
+	assert.Equal(t, 31, KnownCount("-+++++++++++++++++++++++++++++++-"))
+	assert.Equal(t, 0, KnownCount("---"))
+}
+
+func TestTrimPrimary(t *testing.T) {
+	trimmed, err := TrimPrimary("AKKKDNLLFG", "-+++--+++-")
+	require.NoError(t, err)
+	assert.Equal(t, "KKKLLF", trimmed)
+
+	_, err = TrimPrimary("AKK", "++")
+	assert.Error(t, err)
+	_, err = TrimPrimary("AKK", "+?+")
+	assert.Error(t, err)
+}
+
+func TestRecordMask(t *testing.T) {
+	records := readAll(t, "testdata/sample.txt", ReadOptions{Cheap: true})
+	r := records[0]
+	assert.Equal(t, len(r.Primary)-2, r.KnownCount())
+	for _, gap := range r.Gaps() {
+		assert.NotEqual(t, InternalGap, gap.Kind)
+	}
+	trimmed, err := r.TrimmedPrimary()
+	require.NoError(t, err)
+	assert.Equal(t, r.Primary[1:len(r.Primary)-1], trimmed)
+}
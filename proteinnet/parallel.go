@@ -0,0 +1,263 @@
+package proteinnet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// ParallelOptions configures a ParallelScanner
+type ParallelOptions struct {
+	ReadOptions
+
+	// Workers is the number of chunks parsed at
+	// once, runtime.NumCPU() if zero
+	Workers int
+
+	// ChunkSize is the approximate number of bytes per chunk.
+	// Chunks always start at an [ID] line, so hold at least
+	// one whole record. Defaults to 4MB.
+	ChunkSize int
+
+	// Ordered returns records in the order of the input.
+	// Otherwise they are returned as soon as they are parsed.
+	Ordered bool
+}
+
+const defaultChunkSize = 4 * 1024 * 1024
+
+// chunk is a run of whole records from the input
+type chunk struct {
+	seq  int
+	line int // number of lines before the chunk
+	data []byte
+	err  error
+}
+
+type chunkResult struct {
+	seq     int
+	records []*Record
+	invalid []*ParseError
+	err     error
+}
+
+// ParallelScanner reads records like Scanner, but splits
+// the input into chunks that are parsed concurrently. Close
+// must be called if iteration stops before Next returns false.
+type ParallelScanner struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	ordered bool
+	results chan *chunkResult
+	// tokens bounds the chunks in memory, including
+	// those waiting to be returned in order
+	tokens  chan struct{}
+	pending map[int]*chunkResult
+	nextSeq int
+	current *chunkResult
+	pos     int
+	record  *Record
+	err     error
+	invalid []*ParseError
+}
+
+// NewParallelScanner returns a ParallelScanner reading from r
+func NewParallelScanner(ctx context.Context, r io.Reader, options ParallelOptions) *ParallelScanner {
+	if options.Workers <= 0 {
+		options.Workers = runtime.NumCPU()
+	}
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = defaultChunkSize
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &ParallelScanner{
+		ctx:     ctx,
+		cancel:  cancel,
+		ordered: options.Ordered,
+		results: make(chan *chunkResult, options.Workers),
+		tokens:  make(chan struct{}, options.Workers*2),
+		pending: make(map[int]*chunkResult),
+	}
+	chunks := make(chan *chunk, options.Workers)
+	go s.split(r, options.ChunkSize, chunks)
+	wg := sync.WaitGroup{}
+	wg.Add(options.Workers)
+	for i := 0; i < options.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			s.work(chunks, options.ReadOptions)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(s.results)
+	}()
+	return s
+}
+
+// split cuts the input into chunks at [ID] lines
+func (s *ParallelScanner) split(r io.Reader, size int, chunks chan<- *chunk) {
+	defer close(chunks)
+	br := bufio.NewReader(r)
+	seq := 0
+	line := 0
+	current := &chunk{}
+	send := func(c *chunk) bool {
+		select {
+		case s.tokens <- struct{}{}:
+		case <-s.ctx.Done():
+			return false
+		}
+		c.seq = seq
+		seq++
+		select {
+		case chunks <- c:
+			return true
+		case <-s.ctx.Done():
+			return false
+		}
+	}
+	atLineStart := true
+	lines := 0
+	for {
+		data, err := br.ReadSlice('\n')
+		lineStart := atLineStart
+		atLineStart = err == nil
+		if lineStart && len(current.data) >= size && isIDLine(data) {
+			next := &chunk{line: line + lines}
+			if !send(current) {
+				return
+			}
+			current, line, lines = next, next.line, 0
+		}
+		current.data = append(current.data, data...)
+		if err == nil {
+			lines++
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		} else if err == io.EOF {
+			break
+		} else if err != nil {
+			current.err = err
+			break
+		}
+	}
+	if len(current.data) > 0 || current.err != nil {
+		send(current)
+	}
+}
+
+func isIDLine(line []byte) bool {
+	return bytes.Equal(bytes.TrimRight(line, "\r\n"), []byte("[ID]"))
+}
+
+// work parses chunks until there are none left
+func (s *ParallelScanner) work(chunks <-chan *chunk, options ReadOptions) {
+	for c := range chunks {
+		result := &chunkResult{seq: c.seq, err: c.err}
+		scanner := NewScanner(s.ctx, bytes.NewReader(c.data), options)
+		for scanner.Next() {
+			result.records = append(result.records, scanner.Record())
+		}
+		for _, perr := range scanner.Invalid() {
+			result.invalid = append(result.invalid, offsetLine(perr, c.line))
+		}
+		if err := scanner.Err(); err != nil {
+			if perr, ok := err.(*ParseError); ok {
+				err = offsetLine(perr, c.line)
+			}
+			result.err = err
+		}
+		select {
+		case s.results <- result:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// offsetLine makes the line of an error within a
+// chunk relative to the start of the input
+func offsetLine(perr *ParseError, lines int) *ParseError {
+	return &ParseError{Line: perr.Line + lines, Err: perr.Err}
+}
+
+// Record returns the record read by the last call to Next
+func (s *ParallelScanner) Record() *Record {
+	return s.record
+}
+
+// Err returns the first error encountered, or nil if
+// the end of the input was reached
+func (s *ParallelScanner) Err() error {
+	return s.err
+}
+
+// Invalid returns the errors of the records that were
+// skipped, when reading with CollectInvalid
+func (s *ParallelScanner) Invalid() []*ParseError {
+	return s.invalid
+}
+
+// nextChunk returns the next chunk to take records from,
+// or nil when there are none left
+func (s *ParallelScanner) nextChunk() *chunkResult {
+	for {
+		if result, ok := s.pending[s.nextSeq]; ok {
+			delete(s.pending, s.nextSeq)
+			s.nextSeq++
+			return result
+		}
+		result, ok := <-s.results
+		if !ok {
+			return nil
+		}
+		if !s.ordered {
+			return result
+		}
+		s.pending[result.seq] = result
+	}
+}
+
+// Next reads the next record, returning false when
+// there are none left or an error occurred
+func (s *ParallelScanner) Next() bool {
+	s.record = nil
+	if s.err != nil {
+		return false
+	}
+	for s.current == nil || s.pos == len(s.current.records) {
+		if s.current != nil {
+			if err := s.current.err; err != nil {
+				s.err = err
+				s.cancel()
+				return false
+			}
+			// Let the splitter read another chunk
+			<-s.tokens
+		}
+		s.current, s.pos = s.nextChunk(), 0
+		if s.current == nil {
+			if err := s.ctx.Err(); err != nil {
+				s.err = err
+			}
+			s.cancel()
+			return false
+		}
+		s.invalid = append(s.invalid, s.current.invalid...)
+	}
+	s.record = s.current.records[s.pos]
+	s.pos++
+	return true
+}
+
+// Close stops reading and waits for the workers to exit
+func (s *ParallelScanner) Close() {
+	s.cancel()
+	for range s.results {
+	}
+}
@@ -0,0 +1,162 @@
+package proteinnet
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// repeatFixture concatenates copies of a fixture, so the
+// records of each copy keep their IDs
+func repeatFixture(t testing.TB, path string, n int) []byte {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	return bytes.Repeat(data, n)
+}
+
+func scanParallel(data []byte, options ParallelOptions) ([]*Record, *ParallelScanner) {
+	scanner := NewParallelScanner(context.Background(), bytes.NewReader(data), options)
+	var records []*Record
+	for scanner.Next() {
+		records = append(records, scanner.Record())
+	}
+	return records, scanner
+}
+
+func scanSequential(data []byte, options ReadOptions) ([]*Record, *Scanner) {
+	scanner := NewScanner(context.Background(), bytes.NewReader(data), options)
+	var records []*Record
+	for scanner.Next() {
+		records = append(records, scanner.Record())
+	}
+	return records, scanner
+}
+
+func TestParallelScannerOrdered(t *testing.T) {
+	data := repeatFixture(t, "testdata/sample.txt", 20)
+	expected, scanner := scanSequential(data, ReadOptions{})
+	require.NoError(t, scanner.Err())
+	require.Len(t, expected, 60)
+	for _, chunkSize := range []int{1, 1000, 1 << 20} {
+		records, parallel := scanParallel(data, ParallelOptions{
+			Workers:   4,
+			ChunkSize: chunkSize,
+			Ordered:   true,
+		})
+		require.NoError(t, parallel.Err())
+		assert.Equal(t, expected, records, "chunk size %d", chunkSize)
+	}
+}
+
+func TestParallelScannerUnordered(t *testing.T) {
+	data := repeatFixture(t, "testdata/sample.txt", 20)
+	expected, _ := scanSequential(data, ReadOptions{Cheap: true})
+	records, parallel := scanParallel(data, ParallelOptions{
+		ReadOptions: ReadOptions{Cheap: true},
+		Workers:     4,
+		ChunkSize:   1,
+	})
+	require.NoError(t, parallel.Err())
+	primaries := func(records []*Record) []string {
+		var result []string
+		for _, r := range records {
+			result = append(result, r.ID.String()+r.Primary)
+		}
+		sort.Strings(result)
+		return result
+	}
+	assert.Equal(t, primaries(expected), primaries(records))
+}
+
+func TestParallelScannerErrors(t *testing.T) {
+	valid := "[ID]\n1ABC_1_A\n[PRIMARY]\nAC\n[MASK]\n++\n\n"
+	invalid := "[ID]\n2ABC_1_A\n[PRIMARY]\nACD\n[MASK]\n++\n\n"
+	data := []byte(strings.Repeat(valid, 5) + invalid + strings.Repeat(valid, 5))
+
+	_, scanner := scanSequential(data, ReadOptions{})
+	require.Error(t, scanner.Err())
+	records, parallel := scanParallel(data, ParallelOptions{
+		Workers:   3,
+		ChunkSize: 1,
+		Ordered:   true,
+	})
+	assert.Len(t, records, 5)
+	assert.Equal(t, scanner.Err(), parallel.Err())
+	assert.EqualError(t, parallel.Err(), "line 36: 2ABC_1_A: mask length (got 2, expected 3)")
+
+	records, parallel = scanParallel(data, ParallelOptions{
+		ReadOptions: ReadOptions{Strictness: CollectInvalid},
+		Workers:     3,
+		ChunkSize:   1,
+		Ordered:     true,
+	})
+	require.NoError(t, parallel.Err())
+	assert.Len(t, records, 10)
+	require.Len(t, parallel.Invalid(), 1)
+	assert.Equal(t, 36, parallel.Invalid()[0].Line)
+}
+
+func TestParallelScannerClose(t *testing.T) {
+	data := repeatFixture(t, "testdata/sample.txt", 50)
+	scanner := NewParallelScanner(context.Background(), bytes.NewReader(data), ParallelOptions{
+		Workers:   2,
+		ChunkSize: 1,
+	})
+	require.True(t, scanner.Next())
+	scanner.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scanner = NewParallelScanner(ctx, bytes.NewReader(data), ParallelOptions{
+		Workers:   2,
+		ChunkSize: 1,
+		Ordered:   true,
+	})
+	require.True(t, scanner.Next())
+	cancel()
+	for scanner.Next() {
+	}
+	assert.Equal(t, context.Canceled, scanner.Err())
+}
+
+func benchmarkData(b *testing.B) []byte {
+	data := repeatFixture(b, "testdata/sample.txt", 2000)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	return data
+}
+
+func BenchmarkScanner(b *testing.B) {
+	data := benchmarkData(b)
+	for i := 0; i < b.N; i++ {
+		if _, scanner := scanSequential(data, ReadOptions{}); scanner.Err() != nil {
+			b.Fatal(scanner.Err())
+		}
+	}
+}
+
+func benchmarkParallelScanner(b *testing.B, ordered bool) {
+	data := benchmarkData(b)
+	for i := 0; i < b.N; i++ {
+		_, scanner := scanParallel(data, ParallelOptions{
+			ChunkSize: 256 * 1024,
+			Ordered:   ordered,
+		})
+		if scanner.Err() != nil {
+			b.Fatal(scanner.Err())
+		}
+	}
+}
+
+func BenchmarkParallelScannerOrdered(b *testing.B) {
+	benchmarkParallelScanner(b, true)
+}
+
+func BenchmarkParallelScannerUnordered(b *testing.B) {
+	benchmarkParallelScanner(b, false)
+}
@@ -1,7 +1,7 @@
 package proteinnet
 
 import (
-	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -9,87 +9,262 @@ import (
 	"strings"
 )
 
-// Record a ProteinNet record
+// AminoAcids is the order of the PSSM rows in
+// the [EVOLUTIONARY] section of a record
+const AminoAcids = "ACDEFGHIKLMNPQRSTVWY"
+
+// NumEvolutionaryRows is the number of lines in an [EVOLUTIONARY]
+// section: one PSSM row per amino acid, then information content
+const NumEvolutionaryRows = len(AminoAcids) + 1
+
+// NumTertiaryRows is the number of lines in a [TERTIARY]
+// section, one for each of the x, y and z coordinates
+const NumTertiaryRows = 3
+
+// Record a ProteinNet record. StructureID, ModelID and
+// ChainID are copied from ID, and are only all set for
+// PDB records.
 type Record struct {
-	StructureID string
-	ModelID     int
-	ChainID     string
-	Primary     string
-	Mask        string
+	ID           ID
+	StructureID  string
+	ModelID      int
+	ChainID      string
+	Primary      string
+	Evolutionary *Evolutionary
+	Secondary    string
+	Tertiary     *Tertiary
+	Mask         string
+
+	// Extra holds sections this package does not
+	// understand, so they can be written back out
+	Extra []Section
+
+	// The sections in the order they were read, the ID line
+	// and the unparsed rows of the float sections, for writing
+	// unmodified records back out byte-for-byte
+	order            []string
+	idRow            string
+	evolutionaryRows []string
+	tertiaryRows     []string
+}
+
+// Section is a record section kept as it was read
+type Section struct {
+	// Name is the section header without brackets
+	Name  string
+	Lines []string
+}
+
+// Evolutionary is the [EVOLUTIONARY] section of a record
+type Evolutionary struct {
+	// PSSM has one row per amino acid, in the order of
+	// AminoAcids, with one column per residue
+	PSSM [len(AminoAcids)][]float64
+
+	// Information is the information content of each residue
+	Information []float64
+}
+
+// Coord is a position in picometers
+type Coord struct {
+	X, Y, Z float64
+}
+
+// Tertiary is the [TERTIARY] section of a record, the
+// positions of the backbone atoms of every residue
+type Tertiary struct {
+	N  []Coord
+	CA []Coord
+	C  []Coord
+}
+
+// ReadOptions ...
+type ReadOptions struct {
+	// Cheap skips parsing the [EVOLUTIONARY] and [TERTIARY]
+	// sections, which make up nearly all of each record.
+	Cheap bool
+
+	// Kinds are the kinds of ID to return records for.
+	// Records of every kind are returned if empty.
+	Kinds []IDKind
+
+	// Strictness is what to do with invalid records
+	Strictness Strictness
+}
+
+// Strictness is how a reader handles invalid records
+type Strictness int
+
+const (
+	// FailInvalid stops reading at the first invalid record
+	FailInvalid Strictness = iota
+
+	// SkipInvalid logs and skips invalid records
+	SkipInvalid
+
+	// CollectInvalid skips invalid records, keeping their
+	// errors for the caller to inspect afterwards
+	CollectInvalid
+)
+
+// ParseStrictness parses "fail", "skip" or "collect"
+func ParseStrictness(s string) (Strictness, error) {
+	switch s {
+	case "fail":
+		return FailInvalid, nil
+	case "skip":
+		return SkipInvalid, nil
+	case "collect":
+		return CollectInvalid, nil
+	default:
+		return FailInvalid, fmt.Errorf("unknown strictness '%s', expected fail, skip or collect", s)
+	}
+}
+
+func (o ReadOptions) keep(kind IDKind) bool {
+	if len(o.Kinds) == 0 {
+		return true
+	}
+	for _, k := range o.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
 }
 
 // ErrSuccessfullyStopped returned by ReadRecords when
 // the reader thread was successfully stopped.
 var ErrSuccessfullyStopped = errors.New("stopped successfully")
 
+// Validate checks that every section of the
+// record has one entry per residue.
+func (r *Record) Validate() error {
+	n := len(r.Primary)
+	if got := len(r.Mask); got != n {
+		return fmt.Errorf("mask length (got %v, expected %v)", got, n)
+	}
+	if r.Secondary != "" {
+		if got := len(r.Secondary); got != n {
+			return fmt.Errorf("secondary length (got %v, expected %v)", got, n)
+		}
+	}
+	if r.Evolutionary != nil {
+		for i, row := range r.Evolutionary.PSSM {
+			if got := len(row); got != n {
+				return fmt.Errorf("evolutionary row %d length (got %v, expected %v)", i, got, n)
+			}
+		}
+		if got := len(r.Evolutionary.Information); got != n {
+			return fmt.Errorf("information length (got %v, expected %v)", got, n)
+		}
+	}
+	if r.Tertiary != nil {
+		for name, coords := range map[string][]Coord{
+			"N":  r.Tertiary.N,
+			"CA": r.Tertiary.CA,
+			"C":  r.Tertiary.C,
+		} {
+			if got := len(coords); got != n {
+				return fmt.Errorf("tertiary %s length (got %v, expected %v)", name, got, n)
+			}
+		}
+	}
+	return nil
+}
+
+func parseFloats(line string) ([]float64, error) {
+	fields := strings.Fields(line)
+	values := make([]float64, len(fields))
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %v", i, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func parseEvolutionary(rows []string) (*Evolutionary, error) {
+	evo := &Evolutionary{}
+	for i, row := range rows {
+		values, err := parseFloats(row)
+		if err != nil {
+			return nil, fmt.Errorf("evolutionary row %d: %v", i, err)
+		}
+		if i < len(evo.PSSM) {
+			evo.PSSM[i] = values
+		} else {
+			evo.Information = values
+		}
+	}
+	return evo, nil
+}
+
+// parseTertiary parses the x, y and z rows of a [TERTIARY]
+// section. Each row has three columns per residue, for its
+// N, CA and C atoms in that order.
+func parseTertiary(rows []string) (*Tertiary, error) {
+	var axes [NumTertiaryRows][]float64
+	for i, row := range rows {
+		values, err := parseFloats(row)
+		if err != nil {
+			return nil, fmt.Errorf("tertiary row %d: %v", i, err)
+		}
+		if len(values)%3 != 0 {
+			return nil, fmt.Errorf("tertiary row %d has %d columns, expected a multiple of 3", i, len(values))
+		}
+		if i > 0 && len(values) != len(axes[0]) {
+			return nil, fmt.Errorf("tertiary row %d has %d columns, expected %d", i, len(values), len(axes[0]))
+		}
+		axes[i] = values
+	}
+	n := len(axes[0]) / 3
+	tertiary := &Tertiary{
+		N:  make([]Coord, n),
+		CA: make([]Coord, n),
+		C:  make([]Coord, n),
+	}
+	for i := 0; i < n; i++ {
+		for j, atoms := range [][]Coord{tertiary.N, tertiary.CA, tertiary.C} {
+			k := i*3 + j
+			atoms[i] = Coord{X: axes[0][k], Y: axes[1][k], Z: axes[2][k]}
+		}
+	}
+	return tertiary, nil
+}
+
+func isHeader(line string) bool {
+	return len(line) > 2 && line[0] == '[' && line[len(line)-1] == ']'
+}
+
 // ReadRecords ...
 func ReadRecords(
 	r io.Reader,
 	results chan<- *Record,
 	stop <-chan int,
+) error {
+	return ReadRecordsWithOptions(r, results, stop, ReadOptions{})
+}
+
+// ReadRecordsWithOptions sends every record read from r to
+// results, closing it when done. It is a wrapper around
+// Scanner for callers that want a channel.
+func ReadRecordsWithOptions(
+	r io.Reader,
+	results chan<- *Record,
+	stop <-chan int,
+	options ReadOptions,
 ) error {
 	defer close(results)
-	scanner := bufio.NewScanner(r)
-	var next *Record
-	for scanner.Scan() {
-		line := scanner.Text()
-		switch line {
-		case "[ID]":
-			if !scanner.Scan() {
-				return fmt.Errorf("expected ID")
-			}
-			id := scanner.Text()
-			parts := strings.Split(id, "_")
-			if len(parts) == 3 {
-				pdbID := parts[0]
-				modelIDStr := parts[1]
-				modelID, err := strconv.ParseInt(modelIDStr, 10, 64)
-				if err != nil {
-					return fmt.Errorf("failed to parse model ID '%v': %v", modelIDStr, err)
-				}
-				chainID := parts[2]
-				next = &Record{
-					StructureID: pdbID,
-					ModelID:     int(modelID),
-					ChainID:     chainID,
-				}
-			} else if len(parts) == 2 {
-				//log.Printf("Skipping ASTRAL %v", id)
-			} else {
-				return fmt.Errorf("malformed ID format '%v'", id)
-			}
-		case "[PRIMARY]":
-			if next != nil {
-				if !scanner.Scan() {
-					return fmt.Errorf("expected primary sequence")
-				}
-				next.Primary = scanner.Text()
-			}
-		case "[MASK]":
-			if next != nil {
-				if !scanner.Scan() {
-					return fmt.Errorf("expected mask")
-				}
-				next.Mask = scanner.Text()
-			}
-		case "":
-			if next != nil {
-				if got, expected := len(next.Mask), len(next.Primary); got != expected {
-					return fmt.Errorf("mask length (got %v, expected %v)", got, expected)
-				}
-				select {
-				case results <- next:
-					next = nil
-					continue
-				case <-stop:
-					return ErrSuccessfullyStopped
-				}
-			}
-			continue
-		default:
-			// Skip the line
-			continue
+	scanner := NewScanner(context.Background(), r, options)
+	for scanner.Next() {
+		select {
+		case results <- scanner.Record():
+		case <-stop:
+			return ErrSuccessfullyStopped
 		}
 	}
-	return nil
+	return scanner.Err()
 }
@@ -0,0 +1,81 @@
+package proteinnet
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, path string, options ReadOptions) []*Record {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	results := make(chan *Record)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- ReadRecordsWithOptions(f, results, nil, options)
+	}()
+	var records []*Record
+	for r := range results {
+		records = append(records, r)
+	}
+	require.NoError(t, <-errc)
+	return records
+}
+
+func TestReadRecordsSections(t *testing.T) {
+	records := readAll(t, "testdata/sample.txt", ReadOptions{})
+	require.Len(t, records, 3)
+	r := records[0]
+	assert.Equal(t, "2L0E", r.StructureID)
+	assert.Equal(t, 1, r.ModelID)
+	assert.Equal(t, "A", r.ChainID)
+	n := len(r.Primary)
+	require.NotNil(t, r.Evolutionary)
+	for _, row := range r.Evolutionary.PSSM {
+		assert.Len(t, row, n)
+	}
+	assert.Len(t, r.Evolutionary.Information, n)
+	assert.InDelta(t, 0.3238, r.Evolutionary.PSSM[0][0], 1e-9)
+	require.NotNil(t, r.Tertiary)
+	assert.Len(t, r.Tertiary.CA, n)
+	// Missing residues have zero coordinates
+	assert.Equal(t, Coord{}, r.Tertiary.CA[0])
+	assert.NotEqual(t, Coord{}, r.Tertiary.CA[1])
+	assert.Equal(t, "", r.Secondary)
+	assert.Equal(t, "CCCHHHHHHCCC", records[1].Secondary)
+}
+
+func TestReadRecordsCheap(t *testing.T) {
+	records := readAll(t, "testdata/sample.txt", ReadOptions{Cheap: true})
+	require.Len(t, records, 3)
+	for _, r := range records {
+		assert.Nil(t, r.Evolutionary)
+		assert.Nil(t, r.Tertiary)
+		assert.NotEmpty(t, r.Primary)
+		assert.NotEmpty(t, r.Mask)
+	}
+}
+
+func TestReadRecordsDimensionMismatch(t *testing.T) {
+	input := strings.Join([]string{
+		"[ID]",
+		"1ABC_1_A",
+		"[PRIMARY]",
+		"ACD",
+		"[TERTIARY]",
+		"1 2 3 4 5 6",
+		"1 2 3 4 5 6",
+		"1 2 3 4 5 6",
+		"[MASK]",
+		"+++",
+		"",
+	}, "\n")
+	results := make(chan *Record, 1)
+	err := ReadRecords(strings.NewReader(input), results, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tertiary")
+}
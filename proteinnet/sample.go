@@ -0,0 +1,127 @@
+package proteinnet
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Sampler picks records from a stream of unknown length
+// while keeping only the sample in memory
+type Sampler interface {
+	// Add offers the next record of the stream
+	Add(r *Record)
+
+	// Records returns the sample in stream order
+	Records() []*Record
+}
+
+type sampled struct {
+	index  int
+	record *Record
+}
+
+// reservoir is a uniform random sample of fixed size
+type reservoir struct {
+	n     int
+	seen  int
+	items []sampled
+}
+
+func (res *reservoir) add(rng *rand.Rand, index int, r *Record) {
+	res.seen++
+	if len(res.items) < res.n {
+		res.items = append(res.items, sampled{index, r})
+		return
+	}
+	if j := rng.Intn(res.seen); j < res.n {
+		res.items[j] = sampled{index, r}
+	}
+}
+
+func inOrder(items []sampled) []*Record {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].index < items[j].index
+	})
+	records := make([]*Record, len(items))
+	for i, item := range items {
+		records[i] = item.record
+	}
+	return records
+}
+
+type randomSampler struct {
+	rng   *rand.Rand
+	count int
+	res   reservoir
+}
+
+// NewRandomSampler returns a Sampler that picks n records
+// uniformly at random. The same seed and input always
+// produce the same sample.
+func NewRandomSampler(n int, seed int64) Sampler {
+	return &randomSampler{
+		rng: rand.New(rand.NewSource(seed)),
+		res: reservoir{n: n},
+	}
+}
+
+func (s *randomSampler) Add(r *Record) {
+	s.res.add(s.rng, s.count, r)
+	s.count++
+}
+
+func (s *randomSampler) Records() []*Record {
+	items := append([]sampled{}, s.res.items...)
+	return inOrder(items)
+}
+
+// Stratum assigns a record to a group for stratified sampling
+type Stratum func(r *Record) string
+
+// LengthStratum groups records into bins of width residues
+func LengthStratum(width int) Stratum {
+	return func(r *Record) string {
+		lo := len(r.Primary) / width * width
+		return fmt.Sprintf("%d-%d", lo, lo+width-1)
+	}
+}
+
+type stratifiedSampler struct {
+	rng     *rand.Rand
+	n       int
+	count   int
+	stratum Stratum
+	strata  map[string]*reservoir
+}
+
+// NewStratifiedSampler returns a Sampler that picks up to n
+// records at random from each stratum, so that rare kinds
+// of record are not crowded out by common ones.
+func NewStratifiedSampler(n int, seed int64, stratum Stratum) Sampler {
+	return &stratifiedSampler{
+		rng:     rand.New(rand.NewSource(seed)),
+		n:       n,
+		stratum: stratum,
+		strata:  make(map[string]*reservoir),
+	}
+}
+
+func (s *stratifiedSampler) Add(r *Record) {
+	key := s.stratum(r)
+	res, ok := s.strata[key]
+	if !ok {
+		res = &reservoir{n: s.n}
+		s.strata[key] = res
+	}
+	res.add(s.rng, s.count, r)
+	s.count++
+}
+
+func (s *stratifiedSampler) Records() []*Record {
+	var items []sampled
+	for _, res := range s.strata {
+		items = append(items, res.items...)
+	}
+	return inOrder(items)
+}
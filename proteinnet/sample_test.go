@@ -0,0 +1,73 @@
+package proteinnet
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleInput(n int) []*Record {
+	records := make([]*Record, n)
+	for i := range records {
+		length := 10 + i%3*100
+		records[i] = &Record{
+			ID:      ID{Kind: KindPDB, StructureID: fmt.Sprintf("%04d", i), ModelID: 1, ChainID: "A"},
+			Primary: strings.Repeat("A", length),
+			Mask:    strings.Repeat("+", length),
+		}
+	}
+	return records
+}
+
+func ids(records []*Record) []string {
+	result := make([]string, len(records))
+	for i, r := range records {
+		result[i] = r.ID.StructureID
+	}
+	return result
+}
+
+func TestRandomSampler(t *testing.T) {
+	input := sampleInput(100)
+	sample := func(seed int64) []*Record {
+		s := NewRandomSampler(10, seed)
+		for _, r := range input {
+			s.Add(r)
+		}
+		return s.Records()
+	}
+	a := sample(1)
+	require.Len(t, a, 10)
+	assert.Equal(t, ids(a), ids(sample(1)))
+	assert.NotEqual(t, ids(a), ids(sample(2)))
+	for i := 1; i < len(a); i++ {
+		assert.Less(t, a[i-1].ID.StructureID, a[i].ID.StructureID, "sample is in input order")
+	}
+
+	// Small inputs are returned whole
+	s := NewRandomSampler(10, 1)
+	for _, r := range input[:3] {
+		s.Add(r)
+	}
+	assert.Equal(t, ids(input[:3]), ids(s.Records()))
+}
+
+func TestStratifiedSampler(t *testing.T) {
+	s := NewStratifiedSampler(4, 1, LengthStratum(100))
+	for _, r := range sampleInput(100) {
+		s.Add(r)
+	}
+	counts := map[string]int{}
+	stratum := LengthStratum(100)
+	for _, r := range s.Records() {
+		counts[stratum(r)]++
+	}
+	assert.Equal(t, map[string]int{
+		"0-99":    4,
+		"100-199": 4,
+		"200-299": 4,
+	}, counts)
+}
@@ -0,0 +1,258 @@
+package proteinnet
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+)
+
+// ParseError is an error at a line of a ProteinNet file,
+// or at a record of a TFRecord file
+type ParseError struct {
+	// Line is the 1-based line number the error occurred at,
+	// or the 0-based index of the record if TFRecord is set
+	Line     int
+	TFRecord bool
+	Err      error
+}
+
+func (e *ParseError) Error() string {
+	if e.TFRecord {
+		return fmt.Sprintf("record %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Scanner reads records one at a time. Successive calls to
+// Next step through the records of a file, until it returns
+// false at the end of the input, on error, or when ctx is
+// cancelled. Err then reports why iteration stopped.
+type Scanner struct {
+	ctx     context.Context
+	scanner *bufio.Scanner
+	options ReadOptions
+	line    int
+	record  *Record
+	err     error
+	invalid []*ParseError
+}
+
+// NewScanner returns a Scanner that reads records from r
+func NewScanner(ctx context.Context, r io.Reader, options ReadOptions) *Scanner {
+	scanner := bufio.NewScanner(r)
+	// Tertiary rows of long chains exceed the default limit
+	scanner.Buffer(nil, 64*1024*1024)
+	return &Scanner{
+		ctx:     ctx,
+		scanner: scanner,
+		options: options,
+	}
+}
+
+// Record returns the record read by the last call to Next
+func (s *Scanner) Record() *Record {
+	return s.record
+}
+
+// Err returns the first error encountered, or nil if
+// the end of the input was reached
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Invalid returns the errors of the records that were
+// skipped, when reading with CollectInvalid
+func (s *Scanner) Invalid() []*ParseError {
+	return s.invalid
+}
+
+func (s *Scanner) scan() bool {
+	if !s.scanner.Scan() {
+		return false
+	}
+	s.line++
+	return true
+}
+
+func (s *Scanner) fail(line int, err error) bool {
+	s.record = nil
+	s.err = &ParseError{Line: line, Err: err}
+	return false
+}
+
+// reject handles an error in a single record, returning
+// false if the scanner should stop. Otherwise the record
+// is dropped and the scanner moves on to the next one.
+func (s *Scanner) reject(line int, err error) bool {
+	perr := &ParseError{Line: line, Err: err}
+	switch s.options.Strictness {
+	case SkipInvalid:
+		log.Printf("Warning: skipping invalid record: %v", perr)
+	case CollectInvalid:
+		s.invalid = append(s.invalid, perr)
+	default:
+		return s.fail(line, err)
+	}
+	return true
+}
+
+func (s *Scanner) scanLines(n int, section string) ([]string, error) {
+	lines := make([]string, n)
+	for i := range lines {
+		if !s.scan() {
+			return nil, fmt.Errorf("expected %d lines of %s, got %d", n, section, i)
+		}
+		lines[i] = s.scanner.Text()
+	}
+	return lines, nil
+}
+
+// Next reads the next record, returning false when
+// there are none left or an error occurred
+func (s *Scanner) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	s.record = nil
+	var next *Record
+	var extra *Section
+	start := 0
+	// emit returns whether Next should return, which
+	// it should not if the record was rejected
+	emit := func() bool {
+		if err := next.Validate(); err != nil {
+			if !s.reject(start, fmt.Errorf("%s: %v", next.ID, err)) {
+				return true
+			}
+			next = nil
+			return false
+		}
+		s.record = next
+		return true
+	}
+	for {
+		if err := s.ctx.Err(); err != nil {
+			s.err = err
+			return false
+		}
+		if !s.scan() {
+			break
+		}
+		line := s.scanner.Text()
+		if next != nil && isHeader(line) && line != "[ID]" {
+			next.order = append(next.order, line)
+			extra = nil
+		}
+		switch line {
+		case "[ID]":
+			if !s.scan() {
+				return s.fail(s.line, fmt.Errorf("expected ID"))
+			}
+			id, err := ParseID(s.scanner.Text())
+			if err != nil {
+				if !s.reject(s.line, err) {
+					return false
+				}
+				next = nil
+				continue
+			}
+			if !s.options.keep(id.Kind) {
+				// Skip the sections of this record
+				next = nil
+				continue
+			}
+			start = s.line - 1
+			next = &Record{
+				ID:          id,
+				StructureID: id.StructureID,
+				ModelID:     id.ModelID,
+				ChainID:     id.ChainID,
+				order:       []string{line},
+				idRow:       s.scanner.Text(),
+			}
+		case "[PRIMARY]":
+			if next != nil {
+				if !s.scan() {
+					return s.fail(s.line, fmt.Errorf("expected primary sequence"))
+				}
+				next.Primary = s.scanner.Text()
+			}
+		case "[EVOLUTIONARY]":
+			if next != nil {
+				header := s.line
+				rows, err := s.scanLines(NumEvolutionaryRows, "evolutionary")
+				if err != nil {
+					return s.fail(s.line, err)
+				}
+				next.evolutionaryRows = rows
+				if !s.options.Cheap {
+					if next.Evolutionary, err = parseEvolutionary(rows); err != nil {
+						if !s.reject(header, fmt.Errorf("%s: %v", next.ID, err)) {
+							return false
+						}
+						next = nil
+					}
+				}
+			}
+		case "[SECONDARY]":
+			if next != nil {
+				if !s.scan() {
+					return s.fail(s.line, fmt.Errorf("expected secondary structure"))
+				}
+				next.Secondary = s.scanner.Text()
+			}
+		case "[TERTIARY]":
+			if next != nil {
+				header := s.line
+				rows, err := s.scanLines(NumTertiaryRows, "tertiary")
+				if err != nil {
+					return s.fail(s.line, err)
+				}
+				next.tertiaryRows = rows
+				if !s.options.Cheap {
+					if next.Tertiary, err = parseTertiary(rows); err != nil {
+						if !s.reject(header, fmt.Errorf("%s: %v", next.ID, err)) {
+							return false
+						}
+						next = nil
+					}
+				}
+			}
+		case "[MASK]":
+			if next != nil {
+				if !s.scan() {
+					return s.fail(s.line, fmt.Errorf("expected mask"))
+				}
+				next.Mask = s.scanner.Text()
+			}
+		case "":
+			extra = nil
+			if next != nil && emit() {
+				return s.err == nil
+			}
+		default:
+			if next == nil {
+				// Skip lines outside of any record
+				continue
+			}
+			if isHeader(line) {
+				next.Extra = append(next.Extra, Section{
+					Name: line[1 : len(line)-1],
+				})
+				extra = &next.Extra[len(next.Extra)-1]
+			} else if extra != nil {
+				extra.Lines = append(extra.Lines, line)
+			}
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return s.fail(s.line, err)
+	}
+	if next != nil && emit() {
+		// The input did not end with a blank line
+		return s.err == nil
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package proteinnet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanner(t *testing.T) {
+	f, err := os.Open("testdata/sample.txt")
+	require.NoError(t, err)
+	defer f.Close()
+	scanner := NewScanner(context.Background(), f, ReadOptions{Cheap: true})
+	var ids []string
+	for scanner.Next() {
+		ids = append(ids, scanner.Record().ID.String())
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, []string{"2L0E_1_A", "1D2S_1_A", "3HTY_2_B"}, ids)
+	assert.Nil(t, scanner.Record())
+	assert.False(t, scanner.Next())
+}
+
+func TestScannerLineNumbers(t *testing.T) {
+	input := strings.Join([]string{
+		"[ID]",
+		"1ABC_1_A",
+		"[PRIMARY]",
+		"AC",
+		"[MASK]",
+		"++",
+		"",
+		"[ID]",
+		"2ABC_1_A",
+		"[PRIMARY]",
+		"ACD",
+		"[TERTIARY]",
+		"1 2 3 4 5 6 7 8 9",
+		"1 2 3 4 5 6 7 8 x",
+		"1 2 3 4 5 6 7 8 9",
+		"[MASK]",
+		"+++",
+		"",
+	}, "\n")
+	scanner := NewScanner(context.Background(), strings.NewReader(input), ReadOptions{})
+	require.True(t, scanner.Next())
+	assert.Equal(t, "1ABC", scanner.Record().StructureID)
+	require.False(t, scanner.Next())
+	var perr *ParseError
+	require.True(t, errors.As(scanner.Err(), &perr))
+	assert.Equal(t, 12, perr.Line)
+	assert.Contains(t, perr.Error(), "line 12: 2ABC_1_A: tertiary row 1")
+
+	// Validation errors point at the start of the record
+	input = "\n[ID]\n1ABC_1_A\n[PRIMARY]\nACD\n[MASK]\n++\n"
+	scanner = NewScanner(context.Background(), strings.NewReader(input), ReadOptions{})
+	require.False(t, scanner.Next())
+	assert.EqualError(t, scanner.Err(), "line 2: 1ABC_1_A: mask length (got 2, expected 3)")
+}
+
+func TestScannerCancel(t *testing.T) {
+	f, err := os.Open("testdata/sample.txt")
+	require.NoError(t, err)
+	defer f.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	scanner := NewScanner(ctx, f, ReadOptions{})
+	require.True(t, scanner.Next())
+	cancel()
+	assert.False(t, scanner.Next())
+	assert.Equal(t, context.Canceled, scanner.Err())
+}
+
+func TestReadRecordsStop(t *testing.T) {
+	f, err := os.Open("testdata/sample.txt")
+	require.NoError(t, err)
+	defer f.Close()
+	results := make(chan *Record)
+	stop := make(chan int)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- ReadRecords(f, results, stop)
+	}()
+	<-results
+	close(stop)
+	assert.Equal(t, ErrSuccessfullyStopped, <-errc)
+}
+
+func TestScannerStrictness(t *testing.T) {
+	input := strings.Join([]string{
+		"[ID]",
+		"1ABC_1_A",
+		"[PRIMARY]",
+		"ACD",
+		"[MASK]",
+		"++",
+		"",
+		"[ID]",
+		"not-an-id",
+		"[PRIMARY]",
+		"AC",
+		"[MASK]",
+		"++",
+		"",
+		"[ID]",
+		"2ABC_1_A",
+		"[PRIMARY]",
+		"AC",
+		"[TERTIARY]",
+		"1 2 3 4 5 6",
+		"1 2 3 x 5 6",
+		"1 2 3 4 5 6",
+		"[MASK]",
+		"++",
+		"",
+		"[ID]",
+		"3ABC_1_A",
+		"[PRIMARY]",
+		"AC",
+		"[MASK]",
+		"++",
+		"",
+	}, "\n")
+	read := func(strictness Strictness) ([]string, *Scanner) {
+		scanner := NewScanner(context.Background(), strings.NewReader(input), ReadOptions{
+			Strictness: strictness,
+		})
+		var ids []string
+		for scanner.Next() {
+			ids = append(ids, scanner.Record().ID.String())
+		}
+		return ids, scanner
+	}
+
+	ids, scanner := read(FailInvalid)
+	assert.Empty(t, ids)
+	assert.EqualError(t, scanner.Err(), "line 1: 1ABC_1_A: mask length (got 2, expected 3)")
+
+	ids, scanner = read(SkipInvalid)
+	assert.Equal(t, []string{"3ABC_1_A"}, ids)
+	assert.NoError(t, scanner.Err())
+	assert.Empty(t, scanner.Invalid())
+
+	ids, scanner = read(CollectInvalid)
+	assert.Equal(t, []string{"3ABC_1_A"}, ids)
+	assert.NoError(t, scanner.Err())
+	var lines []int
+	for _, err := range scanner.Invalid() {
+		lines = append(lines, err.Line)
+	}
+	assert.Equal(t, []int{1, 9, 19}, lines)
+}
+
+func TestParseStrictness(t *testing.T) {
+	for s, expected := range map[string]Strictness{
+		"fail":    FailInvalid,
+		"skip":    SkipInvalid,
+		"collect": CollectInvalid,
+	} {
+		strictness, err := ParseStrictness(s)
+		require.NoError(t, err)
+		assert.Equal(t, expected, strictness)
+	}
+	_, err := ParseStrictness("lenient")
+	assert.Error(t, err)
+}
@@ -0,0 +1,124 @@
+"""Converts a ProteinNet text file to the TFRecord format.
+
+Used to generate the TFRecord fixtures without TensorFlow:
+
+    python3 make_tfrecord.py sample.txt sample.tfrecord
+"""
+import struct
+import sys
+
+AMINO_ACIDS = 'ACDEFGHIKLMNPQRSTVWY'
+# DSSP classes in the order used by ProteinNet. The coil 'C'
+# of the text fixtures has no class of its own and maps to L.
+DSSP = 'LHBEGITS'
+
+
+def crc32c(data):
+    crc = 0xffffffff
+    for b in data:
+        crc ^= b
+        for _ in range(8):
+            crc = (crc >> 1) ^ (0x82f63b78 if crc & 1 else 0)
+    return crc ^ 0xffffffff
+
+
+def masked_crc(data):
+    crc = crc32c(data)
+    return ((((crc >> 15) | (crc << 17)) & 0xffffffff) + 0xa282ead8) & 0xffffffff
+
+
+def varint(n):
+    out = bytearray()
+    while True:
+        b = n & 0x7f
+        n >>= 7
+        if n:
+            out.append(b | 0x80)
+        else:
+            out.append(b)
+            return bytes(out)
+
+
+def field(number, payload):
+    return varint(number << 3 | 2) + varint(len(payload)) + payload
+
+
+def bytes_feature(values):
+    return field(1, b''.join(field(1, v) for v in values))
+
+
+def float_feature(values):
+    return field(2, field(1, b''.join(struct.pack('<f', v) for v in values)))
+
+
+def int64_feature(values):
+    return field(3, field(1, b''.join(varint(v) for v in values)))
+
+
+def feature_list(features):
+    return b''.join(field(1, f) for f in features)
+
+
+def sequence_example(record):
+    context = field(1, field(1, b'id') + field(2, bytes_feature([record['id'].encode()])))
+    n = len(record['primary'])
+    lists = {
+        'primary': [int64_feature([AMINO_ACIDS.index(c)]) for c in record['primary']],
+        'mask': [float_feature([1.0 if c == '+' else 0.0]) for c in record['mask']],
+    }
+    if 'evolutionary' in record:
+        rows = record['evolutionary']
+        lists['evolutionary'] = [float_feature([row[i] for row in rows]) for i in range(n)]
+    if 'secondary' in record:
+        lists['secondary'] = [int64_feature([DSSP.index(c if c in DSSP else 'L')])
+                              for c in record['secondary']]
+    if 'tertiary' in record:
+        x, y, z = record['tertiary']
+        lists['tertiary'] = [float_feature([x[i], y[i], z[i]]) for i in range(3 * n)]
+    feature_lists = b''.join(
+        field(1, field(1, name.encode()) + field(2, feature_list(features)))
+        for name, features in lists.items())
+    return field(1, context) + field(2, feature_lists)
+
+
+def read_records(path):
+    with open(path) as f:
+        lines = f.read().split('\n')
+    records = []
+    record = None
+    i = 0
+    while i < len(lines):
+        line = lines[i]
+        if line == '[ID]':
+            record = {'id': lines[i + 1]}
+            records.append(record)
+            i += 2
+        elif line in ('[PRIMARY]', '[SECONDARY]', '[MASK]'):
+            record[line[1:-1].lower()] = lines[i + 1]
+            i += 2
+        elif line == '[EVOLUTIONARY]':
+            record['evolutionary'] = [[float(v) for v in row.split()]
+                                      for row in lines[i + 1:i + 22]]
+            i += 22
+        elif line == '[TERTIARY]':
+            record['tertiary'] = [[float(v) for v in row.split()]
+                                  for row in lines[i + 1:i + 4]]
+            i += 4
+        else:
+            i += 1
+    return records
+
+
+def main(src, dst):
+    with open(dst, 'wb') as out:
+        for record in read_records(src):
+            data = sequence_example(record)
+            length = struct.pack('<Q', len(data))
+            out.write(length)
+            out.write(struct.pack('<I', masked_crc(length)))
+            out.write(data)
+            out.write(struct.pack('<I', masked_crc(data)))
+
+
+if __name__ == '__main__':
+    main(sys.argv[1], sys.argv[2])
@@ -0,0 +1,415 @@
+package proteinnet
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+)
+
+// SecondaryStructures are the DSSP classes of the secondary
+// feature of ProteinNet TFRecords, in order of their index
+const SecondaryStructures = "LHBEGITS"
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCorrupt is returned when a TFRecord checksum does not match
+var ErrCorrupt = errors.New("tfrecord checksum mismatch")
+
+func maskedCRC(data []byte) uint32 {
+	crc := crc32.Checksum(data, crc32c)
+	return ((crc >> 15) | (crc << 17)) + 0xa282ead8
+}
+
+// TFRecordReader reads the framing of a TFRecord file: each
+// record is a little-endian uint64 length, the masked CRC32-C
+// of the length, the payload, then the masked CRC of that.
+type TFRecordReader struct {
+	r *bufio.Reader
+}
+
+// NewTFRecordReader returns a TFRecordReader reading from r
+func NewTFRecordReader(r io.Reader) *TFRecordReader {
+	return &TFRecordReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the payload of the next record, or
+// io.EOF when there are no records left
+func (t *TFRecordReader) Next() ([]byte, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(t.r, header[:]); err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("read length: %v", err)
+	}
+	if maskedCRC(header[:8]) != binary.LittleEndian.Uint32(header[8:]) {
+		return nil, fmt.Errorf("length: %v", ErrCorrupt)
+	}
+	length := binary.LittleEndian.Uint64(header[:8])
+	if length > math.MaxInt32 {
+		return nil, fmt.Errorf("record length %d too large", length)
+	}
+	data := make([]byte, length+4)
+	if _, err := io.ReadFull(t.r, data); err != nil {
+		return nil, fmt.Errorf("read payload: %v", err)
+	}
+	payload := data[:length]
+	if maskedCRC(payload) != binary.LittleEndian.Uint32(data[length:]) {
+		return nil, fmt.Errorf("payload: %v", ErrCorrupt)
+	}
+	return payload, nil
+}
+
+// TFRecordScanner reads records from a ProteinNet TFRecord
+// file, the counterpart of Scanner for the text format
+type TFRecordScanner struct {
+	ctx     context.Context
+	reader  *TFRecordReader
+	options ReadOptions
+	index   int
+	record  *Record
+	err     error
+	invalid []*ParseError
+}
+
+// NewTFRecordScanner returns a TFRecordScanner reading from r
+func NewTFRecordScanner(ctx context.Context, r io.Reader, options ReadOptions) *TFRecordScanner {
+	return &TFRecordScanner{
+		ctx:     ctx,
+		reader:  NewTFRecordReader(r),
+		options: options,
+	}
+}
+
+// Record returns the record read by the last call to Next
+func (s *TFRecordScanner) Record() *Record {
+	return s.record
+}
+
+// Err returns the first error encountered, or nil if
+// the end of the input was reached
+func (s *TFRecordScanner) Err() error {
+	return s.err
+}
+
+// Invalid returns the errors of the records that were
+// skipped, when reading with CollectInvalid
+func (s *TFRecordScanner) Invalid() []*ParseError {
+	return s.invalid
+}
+
+// Next reads the next record, returning false when
+// there are none left or an error occurred
+func (s *TFRecordScanner) Next() bool {
+	s.record = nil
+	for s.err == nil {
+		if err := s.ctx.Err(); err != nil {
+			s.err = err
+			return false
+		}
+		payload, err := s.reader.Next()
+		if err == io.EOF {
+			return false
+		} else if err != nil {
+			// The framing is lost, so there is no next record
+			s.err = &ParseError{Line: s.index, TFRecord: true, Err: err}
+			return false
+		}
+		index := s.index
+		s.index++
+		r, err := decodeSequenceExample(payload, s.options)
+		if err != nil {
+			perr := &ParseError{Line: index, TFRecord: true, Err: err}
+			switch s.options.Strictness {
+			case SkipInvalid:
+				log.Printf("Warning: skipping invalid record: %v", perr)
+			case CollectInvalid:
+				s.invalid = append(s.invalid, perr)
+			default:
+				s.err = perr
+			}
+			continue
+		}
+		if r == nil {
+			// Not one of the requested kinds
+			continue
+		}
+		s.record = r
+		return true
+	}
+	return false
+}
+
+// feature is a tf.train.Feature, which holds one of
+// the three kinds of list
+type feature struct {
+	bytes  [][]byte
+	floats []float32
+	ints   []int64
+}
+
+// sequenceExample is a decoded tf.train.SequenceExample
+type sequenceExample struct {
+	context  map[string]feature
+	features map[string][]feature
+}
+
+// wireFields calls fn with each field of a protobuf message.
+// value is the payload of length-delimited fields, and the
+// raw varint or fixed-width value otherwise.
+func wireFields(data []byte, fn func(number int, wireType int, value []byte, varint uint64) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("malformed field key")
+		}
+		data = data[n:]
+		number, wireType := int(key>>3), int(key&7)
+		var value []byte
+		var v uint64
+		switch wireType {
+		case 0:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return fmt.Errorf("malformed varint in field %d", number)
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return fmt.Errorf("truncated field %d", number)
+			}
+			value, data = data[:8], data[8:]
+		case 2:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return fmt.Errorf("truncated field %d", number)
+			}
+			value, data = data[n:n+int(length)], data[n+int(length):]
+		case 5:
+			if len(data) < 4 {
+				return fmt.Errorf("truncated field %d", number)
+			}
+			value, data = data[:4], data[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d in field %d", wireType, number)
+		}
+		if err := fn(number, wireType, value, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeFeature(data []byte) (feature, error) {
+	var f feature
+	err := wireFields(data, func(number, _ int, list []byte, _ uint64) error {
+		// Each kind of list has its values in field 1, which
+		// may be packed or repeated
+		return wireFields(list, func(field, wireType int, value []byte, v uint64) error {
+			if field != 1 {
+				return nil
+			}
+			switch number {
+			case 1:
+				f.bytes = append(f.bytes, value)
+			case 2:
+				if wireType == 5 {
+					f.floats = append(f.floats, math.Float32frombits(binary.LittleEndian.Uint32(value)))
+					return nil
+				}
+				if len(value)%4 != 0 {
+					return fmt.Errorf("packed float list of %d bytes", len(value))
+				}
+				for i := 0; i < len(value); i += 4 {
+					f.floats = append(f.floats, math.Float32frombits(binary.LittleEndian.Uint32(value[i:])))
+				}
+			case 3:
+				if wireType == 0 {
+					f.ints = append(f.ints, int64(v))
+					return nil
+				}
+				for len(value) > 0 {
+					x, n := binary.Uvarint(value)
+					if n <= 0 {
+						return fmt.Errorf("malformed packed int64 list")
+					}
+					f.ints = append(f.ints, int64(x))
+					value = value[n:]
+				}
+			}
+			return nil
+		})
+	})
+	return f, err
+}
+
+// mapEntry decodes an entry of a protobuf map<string, ...>
+func mapEntry(data []byte) (key string, value []byte, err error) {
+	err = wireFields(data, func(number, _ int, v []byte, _ uint64) error {
+		switch number {
+		case 1:
+			key = string(v)
+		case 2:
+			value = v
+		}
+		return nil
+	})
+	return
+}
+
+func decodeSequenceExampleFields(data []byte) (*sequenceExample, error) {
+	ex := &sequenceExample{
+		context:  make(map[string]feature),
+		features: make(map[string][]feature),
+	}
+	err := wireFields(data, func(number, _ int, value []byte, _ uint64) error {
+		return wireFields(value, func(_, _ int, entry []byte, _ uint64) error {
+			key, value, err := mapEntry(entry)
+			if err != nil {
+				return err
+			}
+			switch number {
+			case 1:
+				f, err := decodeFeature(value)
+				if err != nil {
+					return fmt.Errorf("context %s: %v", key, err)
+				}
+				ex.context[key] = f
+			case 2:
+				var list []feature
+				if err := wireFields(value, func(_, _ int, value []byte, _ uint64) error {
+					f, err := decodeFeature(value)
+					list = append(list, f)
+					return err
+				}); err != nil {
+					return fmt.Errorf("feature list %s: %v", key, err)
+				}
+				ex.features[key] = list
+			}
+			return nil
+		})
+	})
+	return ex, err
+}
+
+// floats returns the values of the named feature list, which
+// must have width values per step
+func (ex *sequenceExample) floats(name string, width int) ([][]float32, error) {
+	list := ex.features[name]
+	steps := make([][]float32, len(list))
+	for i, f := range list {
+		if len(f.floats) != width {
+			return nil, fmt.Errorf("%s step %d has %d values, expected %d", name, i, len(f.floats), width)
+		}
+		steps[i] = f.floats
+	}
+	return steps, nil
+}
+
+// letters maps the indices of the named feature list to
+// letters of alphabet
+func (ex *sequenceExample) letters(name, alphabet string) (string, error) {
+	list := ex.features[name]
+	b := make([]byte, len(list))
+	for i, f := range list {
+		if len(f.ints) != 1 || f.ints[0] < 0 || f.ints[0] >= int64(len(alphabet)) {
+			return "", fmt.Errorf("%s step %d: invalid index %v", name, i, f.ints)
+		}
+		b[i] = alphabet[f.ints[0]]
+	}
+	return string(b), nil
+}
+
+// decodeSequenceExample converts a serialized ProteinNet
+// SequenceExample to a Record, returning nil if it is not
+// of a kind requested by options.
+func decodeSequenceExample(data []byte, options ReadOptions) (*Record, error) {
+	ex, err := decodeSequenceExampleFields(data)
+	if err != nil {
+		return nil, err
+	}
+	idFeature := ex.context["id"]
+	if len(idFeature.bytes) != 1 {
+		return nil, fmt.Errorf("missing id")
+	}
+	id, err := ParseID(string(idFeature.bytes[0]))
+	if err != nil {
+		return nil, err
+	}
+	if !options.keep(id.Kind) {
+		return nil, nil
+	}
+	r := &Record{
+		ID:          id,
+		StructureID: id.StructureID,
+		ModelID:     id.ModelID,
+		ChainID:     id.ChainID,
+	}
+	if r.Primary, err = ex.letters("primary", AminoAcids); err != nil {
+		return nil, fmt.Errorf("%s: %v", id, err)
+	}
+	if _, ok := ex.features["secondary"]; ok {
+		if r.Secondary, err = ex.letters("secondary", SecondaryStructures); err != nil {
+			return nil, fmt.Errorf("%s: %v", id, err)
+		}
+	}
+	mask, err := ex.floats("mask", 1)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", id, err)
+	}
+	maskBytes := make([]byte, len(mask))
+	for i, m := range mask {
+		maskBytes[i] = Missing
+		if m[0] != 0 {
+			maskBytes[i] = Known
+		}
+	}
+	r.Mask = string(maskBytes)
+	if _, ok := ex.features["evolutionary"]; ok && !options.Cheap {
+		steps, err := ex.floats("evolutionary", NumEvolutionaryRows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", id, err)
+		}
+		evo := &Evolutionary{Information: make([]float64, len(steps))}
+		for j := range evo.PSSM {
+			evo.PSSM[j] = make([]float64, len(steps))
+		}
+		for i, step := range steps {
+			for j := range evo.PSSM {
+				evo.PSSM[j][i] = float64(step[j])
+			}
+			evo.Information[i] = float64(step[len(evo.PSSM)])
+		}
+		r.Evolutionary = evo
+	}
+	if _, ok := ex.features["tertiary"]; ok && !options.Cheap {
+		steps, err := ex.floats("tertiary", NumTertiaryRows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", id, err)
+		}
+		if len(steps)%3 != 0 {
+			return nil, fmt.Errorf("%s: tertiary has %d atoms, expected a multiple of 3", id, len(steps))
+		}
+		n := len(steps) / 3
+		tertiary := &Tertiary{
+			N:  make([]Coord, n),
+			CA: make([]Coord, n),
+			C:  make([]Coord, n),
+		}
+		for i := 0; i < n; i++ {
+			for j, atoms := range [][]Coord{tertiary.N, tertiary.CA, tertiary.C} {
+				p := steps[i*3+j]
+				atoms[i] = Coord{X: float64(p[0]), Y: float64(p[1]), Z: float64(p[2])}
+			}
+		}
+		r.Tertiary = tertiary
+	}
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", id, err)
+	}
+	return r, nil
+}
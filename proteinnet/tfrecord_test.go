@@ -0,0 +1,156 @@
+package proteinnet
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTFRecords(t *testing.T, data []byte, options ReadOptions) ([]*Record, error) {
+	scanner := NewTFRecordScanner(context.Background(), bytes.NewReader(data), options)
+	var records []*Record
+	for scanner.Next() {
+		records = append(records, scanner.Record())
+	}
+	return records, scanner.Err()
+}
+
+func TestTFRecordMatchesText(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/sample.tfrecord")
+	require.NoError(t, err)
+	records, err := readTFRecords(t, data, ReadOptions{})
+	require.NoError(t, err)
+	expected := readAll(t, "testdata/sample.txt", ReadOptions{})
+	require.Len(t, records, len(expected))
+	for i, r := range records {
+		e := expected[i]
+		assert.Equal(t, e.ID, r.ID)
+		assert.Equal(t, e.StructureID, r.StructureID)
+		assert.Equal(t, e.Primary, r.Primary)
+		assert.Equal(t, e.Mask, r.Mask)
+		require.NotNil(t, r.Evolutionary)
+		for j := range e.Evolutionary.PSSM {
+			assert.InDeltaSlice(t, e.Evolutionary.PSSM[j], r.Evolutionary.PSSM[j], 1e-4)
+		}
+		assert.InDeltaSlice(t, e.Evolutionary.Information, r.Evolutionary.Information, 1e-4)
+		require.NotNil(t, r.Tertiary)
+		require.Len(t, r.Tertiary.CA, len(e.Tertiary.CA))
+		for j := range e.Tertiary.CA {
+			assert.InDelta(t, e.Tertiary.N[j].X, r.Tertiary.N[j].X, 1e-3)
+			assert.InDelta(t, e.Tertiary.CA[j].Y, r.Tertiary.CA[j].Y, 1e-3)
+			assert.InDelta(t, e.Tertiary.C[j].Z, r.Tertiary.C[j].Z, 1e-3)
+		}
+	}
+	// Coil has no DSSP class of its own
+	assert.Equal(t, "LLLHHHHHHLLL", records[1].Secondary)
+	assert.Equal(t, "", records[0].Secondary)
+}
+
+func TestTFRecordOptions(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/sample.tfrecord")
+	require.NoError(t, err)
+	records, err := readTFRecords(t, data, ReadOptions{Cheap: true})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Nil(t, records[0].Evolutionary)
+	assert.Nil(t, records[0].Tertiary)
+
+	records, err = readTFRecords(t, data, ReadOptions{Kinds: []IDKind{KindCASP}})
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestTFRecordCorrupt(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/sample.tfrecord")
+	require.NoError(t, err)
+
+	corrupt := append([]byte{}, data...)
+	corrupt[20] ^= 0xff
+	records, err := readTFRecords(t, corrupt, ReadOptions{})
+	assert.Empty(t, records)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrCorrupt.Error())
+
+	corrupt = append([]byte{}, data...)
+	corrupt[0] ^= 0xff
+	_, err = readTFRecords(t, corrupt, ReadOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "length")
+
+	records, err = readTFRecords(t, data[:len(data)-10], ReadOptions{})
+	assert.Len(t, records, 2)
+	assert.Error(t, err)
+}
+
+// frameTFRecord frames payload as a record of a TFRecord file
+func frameTFRecord(payload []byte) []byte {
+	var header [12]byte
+	binary.LittleEndian.PutUint64(header[:8], uint64(len(payload)))
+	binary.LittleEndian.PutUint32(header[8:], maskedCRC(header[:8]))
+	var footer [4]byte
+	binary.LittleEndian.PutUint32(footer[:], maskedCRC(payload))
+	return append(append(header[:], payload...), footer[:]...)
+}
+
+func TestTFRecordInvalid(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/sample.tfrecord")
+	require.NoError(t, err)
+	// A record that is framed correctly but isn't an example,
+	// in second place
+	reader := NewTFRecordReader(bytes.NewReader(data))
+	var framed []byte
+	for i := 0; ; i++ {
+		payload, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if i == 1 {
+			framed = append(framed, frameTFRecord([]byte{0xff})...)
+		}
+		framed = append(framed, frameTFRecord(payload)...)
+	}
+
+	_, err = readTFRecords(t, framed, ReadOptions{})
+	require.Error(t, err)
+	perr, ok := err.(*ParseError)
+	require.True(t, ok, "expected a ParseError, got %T", err)
+	assert.Equal(t, 1, perr.Line)
+	assert.True(t, strings.HasPrefix(err.Error(), "record 1: "), err.Error())
+
+	scanner := NewTFRecordScanner(context.Background(), bytes.NewReader(framed), ReadOptions{
+		Strictness: CollectInvalid,
+	})
+	n := 0
+	for scanner.Next() {
+		n++
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, 3, n)
+	require.Len(t, scanner.Invalid(), 1)
+	assert.Equal(t, 1, scanner.Invalid()[0].Line)
+	assert.True(t, scanner.Invalid()[0].TFRecord)
+}
+
+func TestTFRecordReader(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/sample.tfrecord")
+	require.NoError(t, err)
+	reader := NewTFRecordReader(bytes.NewReader(data))
+	total := 0
+	for {
+		payload, err := reader.Next()
+		if err != nil {
+			break
+		}
+		// length, its CRC, the payload and its CRC
+		total += 8 + 4 + len(payload) + 4
+	}
+	assert.Equal(t, len(data), total)
+}
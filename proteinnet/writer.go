@@ -0,0 +1,215 @@
+package proteinnet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// canonicalOrder is the order sections are written in
+// for records that were not read from a file
+var canonicalOrder = []string{
+	"[ID]",
+	"[PRIMARY]",
+	"[EVOLUTIONARY]",
+	"[SECONDARY]",
+	"[TERTIARY]",
+	"[MASK]",
+}
+
+// Writer writes records in the ProteinNet text format.
+// Records read with ReadRecords are written back out
+// byte-for-byte unless they were modified.
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter returns a Writer that writes to w. Flush
+// must be called after the last record is written.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Flush writes any buffered data to the underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Write writes a single record, followed by a blank line
+func (w *Writer) Write(r *Record) error {
+	if err := r.Validate(); err != nil {
+		return fmt.Errorf("%s: %v", r.ID, err)
+	}
+	extras := r.Extra
+	for _, header := range r.sections() {
+		var lines []string
+		switch header {
+		case "[ID]":
+			lines = []string{r.formatID()}
+		case "[PRIMARY]":
+			lines = []string{r.Primary}
+		case "[EVOLUTIONARY]":
+			lines = r.formatEvolutionary()
+		case "[SECONDARY]":
+			lines = []string{r.Secondary}
+		case "[TERTIARY]":
+			lines = r.formatTertiary()
+		case "[MASK]":
+			lines = []string{r.Mask}
+		default:
+			// Extra sections are written in the order they were read
+			lines = extras[0].Lines
+			extras = extras[1:]
+		}
+		if _, err := w.w.WriteString(header + "\n"); err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if _, err := w.w.WriteString(line + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := w.w.WriteString("\n")
+	return err
+}
+
+func (r *Record) hasSection(header string) bool {
+	switch header {
+	case "[EVOLUTIONARY]":
+		return r.Evolutionary != nil || r.evolutionaryRows != nil
+	case "[SECONDARY]":
+		return r.Secondary != ""
+	case "[TERTIARY]":
+		return r.Tertiary != nil || r.tertiaryRows != nil
+	default:
+		return true
+	}
+}
+
+// sections returns the headers of the sections to write. The
+// order a record was read in is kept, with any sections added
+// since inserted in canonical order, and extra sections last.
+func (r *Record) sections() []string {
+	var headers []string
+	numExtra := 0
+	for _, header := range r.order {
+		known := false
+		for _, c := range canonicalOrder {
+			if header == c {
+				known = true
+				break
+			}
+		}
+		if known && !r.hasSection(header) {
+			continue
+		}
+		if !known {
+			if numExtra == len(r.Extra) {
+				// The extra section was removed
+				continue
+			}
+			numExtra++
+		}
+		headers = append(headers, header)
+	}
+	for i, c := range canonicalOrder {
+		if !r.hasSection(c) || indexOf(headers, c) >= 0 {
+			continue
+		}
+		// Insert after the closest preceding canonical section
+		at := 0
+		for j := i - 1; j >= 0; j-- {
+			if k := indexOf(headers, canonicalOrder[j]); k >= 0 {
+				at = k + 1
+				break
+			}
+		}
+		headers = append(headers[:at], append([]string{c}, headers[at:]...)...)
+	}
+	for _, extra := range r.Extra[numExtra:] {
+		headers = append(headers, "["+extra.Name+"]")
+	}
+	return headers
+}
+
+func indexOf(values []string, v string) int {
+	for i, value := range values {
+		if value == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func formatFloats(values []float64) string {
+	fields := make([]string, len(values))
+	for i, v := range values {
+		fields[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(fields, "\t")
+}
+
+// formatEvolutionary returns the rows of the [EVOLUTIONARY]
+// section, reusing the rows that were read if they still
+// hold the same values.
+// formatID returns the ID line, as it was read if it still
+// holds the same ID. IDs may be written differently than
+// String would, such as in lower case.
+func (r *Record) formatID() string {
+	if r.idRow != "" {
+		if parsed, err := ParseID(r.idRow); err == nil && parsed == r.ID {
+			return r.idRow
+		}
+	}
+	return r.ID.String()
+}
+
+func (r *Record) formatEvolutionary() []string {
+	if r.Evolutionary == nil {
+		// Read cheaply, the section was never parsed
+		return r.evolutionaryRows
+	}
+	if r.evolutionaryRows != nil {
+		if parsed, err := parseEvolutionary(r.evolutionaryRows); err == nil &&
+			reflect.DeepEqual(parsed, r.Evolutionary) {
+			return r.evolutionaryRows
+		}
+	}
+	rows := make([]string, 0, NumEvolutionaryRows)
+	for _, row := range r.Evolutionary.PSSM {
+		rows = append(rows, formatFloats(row))
+	}
+	return append(rows, formatFloats(r.Evolutionary.Information))
+}
+
+// formatTertiary returns the rows of the [TERTIARY] section,
+// reusing the rows that were read if they still hold the
+// same values.
+func (r *Record) formatTertiary() []string {
+	if r.Tertiary == nil {
+		return r.tertiaryRows
+	}
+	if r.tertiaryRows != nil {
+		if parsed, err := parseTertiary(r.tertiaryRows); err == nil &&
+			reflect.DeepEqual(parsed, r.Tertiary) {
+			return r.tertiaryRows
+		}
+	}
+	var axes [NumTertiaryRows][]float64
+	for i := range r.Tertiary.CA {
+		for _, c := range []Coord{r.Tertiary.N[i], r.Tertiary.CA[i], r.Tertiary.C[i]} {
+			axes[0] = append(axes[0], c.X)
+			axes[1] = append(axes[1], c.Y)
+			axes[2] = append(axes[2], c.Z)
+		}
+	}
+	rows := make([]string, NumTertiaryRows)
+	for i, axis := range axes {
+		rows[i] = formatFloats(axis)
+	}
+	return rows
+}
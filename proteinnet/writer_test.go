@@ -0,0 +1,94 @@
+package proteinnet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeAll(t *testing.T, records []*Record) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, r := range records {
+		require.NoError(t, w.Write(r))
+	}
+	require.NoError(t, w.Flush())
+	return buf.Bytes()
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for _, path := range []string{
+		"testdata/sample.txt",
+		"testdata/ids.txt",
+		// Validation set and lower case IDs, not
+		// written the way String writes them
+		"testdata/raw_ids.txt",
+	} {
+		expected, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		for _, options := range []ReadOptions{{}, {Cheap: true}} {
+			records := readAll(t, path, options)
+			assert.Equal(t, string(expected), string(writeAll(t, records)), path)
+		}
+	}
+}
+
+func TestWriterExtraSections(t *testing.T) {
+	records := readAll(t, "testdata/ids.txt", ReadOptions{})
+	require.Len(t, records[0].Extra, 1)
+	assert.Equal(t, Section{
+		Name:  "RESOLUTION",
+		Lines: []string{"1.8", "X-RAY DIFFRACTION"},
+	}, records[0].Extra[0])
+	assert.Empty(t, records[1].Extra)
+}
+
+func TestWriterModified(t *testing.T) {
+	records := readAll(t, "testdata/sample.txt", ReadOptions{})
+	r := records[0]
+	r.Tertiary.CA[1].X = 1234.5
+	r.Evolutionary = nil
+	r.evolutionaryRows = nil
+	r.Secondary = strings.Repeat("H", len(r.Primary))
+	written := writeAll(t, []*Record{r})
+	assert.NotContains(t, string(written), "[EVOLUTIONARY]")
+	assert.Contains(t, string(written), "[PRIMARY]\n"+r.Primary+"\n[SECONDARY]\n"+r.Secondary+"\n[TERTIARY]\n")
+
+	var buf bytes.Buffer
+	buf.Write(written)
+	reread := make(chan *Record, 1)
+	require.NoError(t, ReadRecords(&buf, reread, nil))
+	decoded := <-reread
+	require.NotNil(t, decoded)
+	assert.Equal(t, r.Tertiary, decoded.Tertiary)
+	assert.Equal(t, r.Secondary, decoded.Secondary)
+
+	// A changed ID is written the way String writes it
+	records = readAll(t, "testdata/raw_ids.txt", ReadOptions{})
+	r = records[1]
+	r.ID.ChainID = "C"
+	assert.True(t, strings.HasPrefix(string(writeAll(t, []*Record{r})), "[ID]\n70#3hTy_2_C\n"))
+}
+
+func TestWriterNewRecord(t *testing.T) {
+	r := &Record{
+		ID:      ID{Kind: KindCASP, Class: "FM", TargetID: "T0800"},
+		Primary: "AC",
+		Mask:    "++",
+		Tertiary: &Tertiary{
+			N:  []Coord{{1, 2, 3}, {10, 20, 30}},
+			CA: []Coord{{1.5, 2.5, 3.5}, {0, 0, 0}},
+			C:  []Coord{{-1, -2, -3}, {4, 5, 6}},
+		},
+		Extra: []Section{{Name: "NOTE", Lines: []string{"synthetic"}}},
+	}
+	assert.Equal(t, "[ID]\nFM#T0800\n[PRIMARY]\nAC\n[TERTIARY]\n"+
+		"1\t1.5\t-1\t10\t0\t4\n"+
+		"2\t2.5\t-2\t20\t0\t5\n"+
+		"3\t3.5\t-3\t30\t0\t6\n"+
+		"[MASK]\n++\n[NOTE]\nsynthetic\n\n", string(writeAll(t, []*Record{r})))
+}
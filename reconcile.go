@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// runSimulationController reconciles every Simulation each
+// resyncInterval, or immediately when a result is broadcast.
+func (s *server) runSimulationController(ctx context.Context) {
+	for {
+		if err := s.reconcileSimulations(); err != nil {
+			log.Printf("Warning: failed to reconcile simulations: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.reconcileNow:
+		case <-time.After(s.resyncInterval):
+		}
+	}
+}
+
+// triggerReconcile wakes the simulation controller, if
+// it is running on this replica.
+func (s *server) triggerReconcile() {
+	select {
+	case s.reconcileNow <- struct{}{}:
+	default:
+	}
+}
+
+func (s *server) reconcileSimulations() error {
+	resp, err := s.simulations.List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("list simulations: %v", err)
+	}
+	for i := range resp.Items {
+		sim, err := simulationFromUnstructured(&resp.Items[i])
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		if sim.Status.IsFinished() {
+			err = s.reconcileFinishedSimulation(sim)
+		} else {
+			err = s.reconcileSimulation(sim)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to reconcile simulation %s: %v", sim.Name, err)
+		}
+	}
+	return nil
+}
+
+// reconcileFinishedSimulation deletes sim once it has been finished
+// for simulationTTL, and clears its ResultLocation once the result
+// has expired from redis, so that it doesn't point at nothing.
+func (s *server) reconcileFinishedSimulation(sim *Simulation) error {
+	finished := sim.CreationTimestamp.Time
+	if t := sim.Status.CompletionTime; t != nil {
+		finished = t.Time
+	}
+	if time.Since(finished) > s.simulationTTL {
+		log.Printf("Deleting simulation %s, finished %v ago", sim.Name, time.Since(finished).Round(time.Second))
+		if err := s.simulations.Delete(sim.Name, &metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("delete simulation: %v", err)
+		}
+		return nil
+	}
+	if sim.Status.ResultLocation == "" {
+		return nil
+	}
+	n, err := s.redis.Exists(rkResult(sim.Status.CorrelationID)).Result()
+	if err != nil {
+		return fmt.Errorf("redis: %v", err)
+	}
+	if n == 0 {
+		log.Printf("Result of simulation %s expired", sim.Name)
+		sim.Status.ResultLocation = ""
+		sim.Status.Message = fmt.Sprintf("result expired after %v", s.pruneResultTimeout)
+		return s.updateSimulationStatus(sim)
+	}
+	return nil
+}
+
+func (s *server) reconcileSimulation(sim *Simulation) error {
+	switch sim.Status.Phase {
+	case "", SimulationPending:
+		return s.startSimulation(sim)
+	case SimulationRunning:
+		return s.syncSimulation(sim)
+	default:
+		return nil
+	}
+}
+
+// startSimulation creates the pod or job for sim. The object name
+// is derived from the simulation's UID, so creating it again after
+// a failed status update is harmless.
+func (s *server) startSimulation(sim *Simulation) error {
+	if err := s.validateRunConfig(&sim.Spec); err != nil {
+		return s.failSimulation(sim, "invalid_spec", err.Error())
+	}
+	correlationID := string(sim.UID)
+	sim.Status.Backend = s.backend
+	if s.backend == backendJob {
+		if err := s.startJob(sim, correlationID); err != nil {
+			return err
+		}
+	} else if err := s.startPod(sim, correlationID); err != nil {
+		return err
+	}
+	now := metav1.Now()
+	sim.Status.Phase = SimulationRunning
+	sim.Status.CorrelationID = correlationID
+	sim.Status.StartTime = &now
+	return s.updateSimulationStatus(sim)
+}
+
+func (s *server) startPod(sim *Simulation, correlationID string) error {
+	pod, err := s.createExperimentPodObject(&sim.Spec, correlationID)
+	if err != nil {
+		return fmt.Errorf("failed to create pod: %v", err)
+	}
+	pod.Labels["simulation"] = sim.Name
+	pod.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(sim, simulationGVK),
+	}
+	if _, err := s.clientset.CoreV1().Pods(s.namespace).Create(
+		context.TODO(),
+		pod,
+		metav1.CreateOptions{},
+	); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("create pod: %v", err)
+	}
+	log.Printf("Simulation %s started pod %s", sim.Name, pod.Name)
+	sim.Status.PodName = pod.Name
+	return nil
+}
+
+// syncSimulation checks whether the pod for sim has reported
+// a result, or has otherwise stopped without reporting one.
+func (s *server) syncSimulation(sim *Simulation) error {
+	payload, err := s.getResult(sim.Status.CorrelationID)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		if !payload.Success {
+			return s.failSimulation(sim, categorizeError(payload.ErrorMsg), payload.ErrorMsg)
+		}
+		log.Printf("Simulation %s succeeded", sim.Name)
+		now := metav1.Now()
+		sim.Status.Phase = SimulationSucceeded
+		sim.Status.CompletionTime = &now
+		sim.Status.ResultLocation = fmt.Sprintf(
+			"http://%s/result?correlation_id=%s",
+			s.foldyOperatorAddress,
+			sim.Status.CorrelationID,
+		)
+		s.cleanupSimulation(sim)
+		return s.updateSimulationStatus(sim)
+	}
+	if sim.Status.Backend == backendJob {
+		return s.syncJob(sim)
+	}
+	if start := sim.Status.StartTime; start != nil && time.Since(start.Time) > s.timeout {
+		return s.failSimulation(sim, "timeout", fmt.Sprintf("timed out after %v", s.timeout))
+	}
+	pod, err := s.clientset.CoreV1().Pods(s.namespace).Get(
+		context.TODO(),
+		sim.Status.PodName,
+		metav1.GetOptions{},
+	)
+	if kerrors.IsNotFound(err) {
+		return s.failSimulation(sim, "pod_missing", fmt.Sprintf("pod %s not found", sim.Status.PodName))
+	} else if err != nil {
+		return fmt.Errorf("get pod: %v", err)
+	}
+	if pod.Status.Phase == v1.PodFailed {
+		return s.failSimulation(sim, "pod_failed", fmt.Sprintf("pod %s failed: %s", pod.Name, pod.Status.Reason))
+	}
+	return nil
+}
+
+func (s *server) failSimulation(sim *Simulation, category string, msg string) error {
+	log.Printf("Simulation %s failed (%s): %s", sim.Name, category, msg)
+	now := metav1.Now()
+	sim.Status.Phase = SimulationFailed
+	sim.Status.CompletionTime = &now
+	sim.Status.ErrorCategory = category
+	sim.Status.Message = msg
+	s.cleanupSimulation(sim)
+	return s.updateSimulationStatus(sim)
+}
+
+// cleanupSimulation stops whatever is still running for a
+// simulation that has reached a terminal phase.
+func (s *server) cleanupSimulation(sim *Simulation) {
+	if sim.Status.Backend == backendJob {
+		if sim.Status.JobName != "" {
+			s.stopJob(sim.Status.JobName)
+		}
+	} else if sim.Status.PodName != "" {
+		s.deletePod(sim.Status.PodName)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// startTestSimulation creates a simulation and
+// reconciles it until its pod is running
+func startTestSimulation(t *testing.T, ts *testServer) *Simulation {
+	sim, err := ts.createSimulation(testRunConfig())
+	require.NoError(t, err)
+	require.NoError(t, ts.reconcileSimulations())
+	sim, err = ts.getSimulation(sim.Name)
+	require.NoError(t, err)
+	require.Equal(t, SimulationRunning, sim.Status.Phase)
+	return sim
+}
+
+func getTestPod(ts *testServer, name string) (*v1.Pod, error) {
+	return ts.kube.CoreV1().Pods(ts.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+// reconcileTestSimulation reconciles sim and returns it as it is now
+func reconcileTestSimulation(t *testing.T, ts *testServer, sim *Simulation) *Simulation {
+	require.NoError(t, ts.reconcileSimulations())
+	sim, err := ts.getSimulation(sim.Name)
+	require.NoError(t, err)
+	return sim
+}
+
+func TestReconcileStartsPod(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	sim, err := ts.createSimulation(testRunConfig())
+	require.NoError(t, err)
+	assert.Equal(t, SimulationPhase(""), sim.Status.Phase)
+	sim = reconcileTestSimulation(t, ts, sim)
+	assert.Equal(t, SimulationRunning, sim.Status.Phase)
+	assert.Equal(t, string(sim.UID), sim.Status.CorrelationID)
+	assert.NotNil(t, sim.Status.StartTime)
+	pod, err := getTestPod(ts, sim.Status.PodName)
+	require.NoError(t, err)
+	assert.Equal(t, sim.Name, pod.Labels["simulation"])
+	// Reconciling again leaves it running
+	sim = reconcileTestSimulation(t, ts, sim)
+	assert.Equal(t, SimulationRunning, sim.Status.Phase)
+}
+
+func TestReconcileResult(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	sim := startTestSimulation(t, ts)
+	require.NoError(t, ts.publishResult(sim.Status.CorrelationID, &BroadcastPayload{
+		Data:    []byte("result"),
+		Success: true,
+	}))
+	sim = reconcileTestSimulation(t, ts, sim)
+	assert.Equal(t, SimulationSucceeded, sim.Status.Phase)
+	assert.NotNil(t, sim.Status.CompletionTime)
+	assert.Contains(t, sim.Status.ResultLocation, sim.Status.CorrelationID)
+	_, err := getTestPod(ts, sim.Status.PodName)
+	assert.True(t, kerrors.IsNotFound(err), "expected the pod to be deleted, got %v", err)
+
+	// The location is cleared once the result expires
+	sim = reconcileTestSimulation(t, ts, sim)
+	assert.NotEmpty(t, sim.Status.ResultLocation)
+	ts.mr.FastForward(ts.pruneResultTimeout + time.Second)
+	sim = reconcileTestSimulation(t, ts, sim)
+	assert.Equal(t, SimulationSucceeded, sim.Status.Phase)
+	assert.Empty(t, sim.Status.ResultLocation)
+	assert.Contains(t, sim.Status.Message, "expired")
+}
+
+func TestReconcileErrorResult(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	sim := startTestSimulation(t, ts)
+	require.NoError(t, ts.publishResult(sim.Status.CorrelationID, &BroadcastPayload{
+		ErrorMsg: "pdb '1aki' not found",
+	}))
+	sim = reconcileTestSimulation(t, ts, sim)
+	assert.Equal(t, SimulationFailed, sim.Status.Phase)
+	assert.Equal(t, "pdb_not_found", sim.Status.ErrorCategory)
+	assert.Empty(t, sim.Status.ResultLocation)
+}
+
+func TestReconcilePodMissing(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	sim := startTestSimulation(t, ts)
+	require.NoError(t, ts.kube.CoreV1().Pods(ts.namespace).Delete(
+		context.TODO(),
+		sim.Status.PodName,
+		&metav1.DeleteOptions{},
+	))
+	sim = reconcileTestSimulation(t, ts, sim)
+	assert.Equal(t, SimulationFailed, sim.Status.Phase)
+	assert.Equal(t, "pod_missing", sim.Status.ErrorCategory)
+	assert.NotNil(t, sim.Status.CompletionTime)
+}
+
+func TestReconcilePodFailed(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	sim := startTestSimulation(t, ts)
+	pod, err := getTestPod(ts, sim.Status.PodName)
+	require.NoError(t, err)
+	pod.Status.Phase = v1.PodFailed
+	pod.Status.Reason = "Evicted"
+	_, err = ts.kube.CoreV1().Pods(ts.namespace).UpdateStatus(context.TODO(), pod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	sim = reconcileTestSimulation(t, ts, sim)
+	assert.Equal(t, SimulationFailed, sim.Status.Phase)
+	assert.Equal(t, "pod_failed", sim.Status.ErrorCategory)
+	assert.Contains(t, sim.Status.Message, "Evicted")
+	_, err = getTestPod(ts, sim.Status.PodName)
+	assert.True(t, kerrors.IsNotFound(err), "expected the pod to be deleted, got %v", err)
+}
+
+func TestReconcileTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	sim := startTestSimulation(t, ts)
+	started := metav1.NewTime(time.Now().Add(-ts.timeout - time.Second))
+	sim.Status.StartTime = &started
+	require.NoError(t, ts.updateSimulationStatus(sim))
+	sim = reconcileTestSimulation(t, ts, sim)
+	assert.Equal(t, SimulationFailed, sim.Status.Phase)
+	assert.Equal(t, "timeout", sim.Status.ErrorCategory)
+}
+
+func TestReconcileDeletesFinished(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	sim := startTestSimulation(t, ts)
+	require.NoError(t, ts.failSimulation(sim, "timeout", "timed out"))
+	sim = reconcileTestSimulation(t, ts, sim)
+	assert.Equal(t, SimulationFailed, sim.Status.Phase)
+
+	finished := metav1.NewTime(time.Now().Add(-ts.simulationTTL - time.Second))
+	sim.Status.CompletionTime = &finished
+	require.NoError(t, ts.updateSimulationStatus(sim))
+	require.NoError(t, ts.reconcileSimulations())
+	_, err := ts.getSimulation(sim.Name)
+	assert.True(t, kerrors.IsNotFound(err), "expected the simulation to be deleted, got %v", err)
+}
@@ -0,0 +1,196 @@
+package render
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/ribbon/pdb"
+)
+
+// CAPositions returns the alpha carbons of model, in order,
+// skipping residues such as waters and ions that aren't amino
+// acids
+func CAPositions(model *pdb.Model) []fauxgl.Vector {
+	var points []fauxgl.Vector
+	for _, residue := range proteinResidues(model) {
+		points = append(points, atomPosition(residue.AtomsByName["CA"]))
+	}
+	return points
+}
+
+func atomPosition(a *pdb.Atom) fauxgl.Vector {
+	return fauxgl.Vector{X: a.X, Y: a.Y, Z: a.Z}
+}
+
+// TransformModel moves every atom of model by m
+func TransformModel(model *pdb.Model, m fauxgl.Matrix) {
+	for _, atoms := range [][]*pdb.Atom{model.Atoms, model.HetAtoms} {
+		for _, a := range atoms {
+			p := m.MulPosition(atomPosition(a))
+			a.X, a.Y, a.Z = p.X, p.Y, p.Z
+		}
+	}
+}
+
+// RMSD returns the root-mean-square deviation of
+// two sets of corresponding points
+func RMSD(a, b []fauxgl.Vector) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("expected the same number of points, got %d and %d", len(a), len(b))
+	}
+	if len(a) == 0 {
+		return 0, fmt.Errorf("no points")
+	}
+	sum := 0.0
+	for i := range a {
+		d := a[i].Sub(b[i])
+		sum += d.Dot(d)
+	}
+	return math.Sqrt(sum / float64(len(a))), nil
+}
+
+func centroid(points []fauxgl.Vector) fauxgl.Vector {
+	var c fauxgl.Vector
+	for _, p := range points {
+		c = c.Add(p)
+	}
+	return c.DivScalar(float64(len(points)))
+}
+
+// Kabsch returns the rigid transform that superposes mobile
+// onto target with the lowest RMSD. The points correspond by
+// index. The rotation is found with the quaternion method of
+// Horn (1987), which never produces a reflection.
+func Kabsch(mobile, target []fauxgl.Vector) (fauxgl.Matrix, error) {
+	if len(mobile) != len(target) {
+		return fauxgl.Matrix{}, fmt.Errorf("expected the same number of points, got %d and %d", len(mobile), len(target))
+	}
+	if len(mobile) == 0 {
+		return fauxgl.Matrix{}, fmt.Errorf("no points to superpose")
+	}
+	cm := centroid(mobile)
+	ct := centroid(target)
+	// Cross-covariance of the centered points
+	var s [3][3]float64
+	for i := range mobile {
+		m := mobile[i].Sub(cm)
+		t := target[i].Sub(ct)
+		a := [3]float64{m.X, m.Y, m.Z}
+		b := [3]float64{t.X, t.Y, t.Z}
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				s[j][k] += a[j] * b[k]
+			}
+		}
+	}
+	n := [4][4]float64{
+		{s[0][0] + s[1][1] + s[2][2], s[1][2] - s[2][1], s[2][0] - s[0][2], s[0][1] - s[1][0]},
+		{s[1][2] - s[2][1], s[0][0] - s[1][1] - s[2][2], s[0][1] + s[1][0], s[2][0] + s[0][2]},
+		{s[2][0] - s[0][2], s[0][1] + s[1][0], -s[0][0] + s[1][1] - s[2][2], s[1][2] + s[2][1]},
+		{s[0][1] - s[1][0], s[2][0] + s[0][2], s[1][2] + s[2][1], -s[0][0] - s[1][1] + s[2][2]},
+	}
+	q := largestEigenvector(n)
+	w, x, y, z := q[0], q[1], q[2], q[3]
+	rotation := fauxgl.Matrix{
+		X00: 1 - 2*(y*y+z*z), X01: 2 * (x*y - w*z), X02: 2 * (x*z + w*y),
+		X10: 2 * (x*y + w*z), X11: 1 - 2*(x*x+z*z), X12: 2 * (y*z - w*x),
+		X20: 2 * (x*z - w*y), X21: 2 * (y*z + w*x), X22: 1 - 2*(x*x+y*y),
+		X33: 1,
+	}
+	// Center on the origin, rotate, then move onto the target
+	return fauxgl.Translate(ct).Mul(rotation).Mul(fauxgl.Translate(cm.Negate())), nil
+}
+
+// largestEigenvector returns the unit eigenvector of the
+// symmetric matrix a with the largest eigenvalue, using
+// cyclic Jacobi rotations
+func largestEigenvector(a [4][4]float64) [4]float64 {
+	var v [4][4]float64
+	for i := range v {
+		v[i][i] = 1
+	}
+	for sweep := 0; sweep < 50; sweep++ {
+		off := 0.0
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				off += a[p][q] * a[p][q]
+			}
+		}
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < 4; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < 4; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < 4; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+	best := 0
+	for i := 1; i < 4; i++ {
+		if a[i][i] > a[best][best] {
+			best = i
+		}
+	}
+	var e [4]float64
+	norm := 0.0
+	for k := 0; k < 4; k++ {
+		e[k] = v[k][best]
+		norm += e[k] * e[k]
+	}
+	norm = math.Sqrt(norm)
+	for k := range e {
+		e[k] /= norm
+	}
+	return e
+}
+
+// Superpose moves model so that its alpha carbons lie on
+// reference with the lowest RMSD, and returns that RMSD
+func Superpose(model *pdb.Model, reference []fauxgl.Vector) (float64, error) {
+	m, rmsd, err := superpose(CAPositions(model), reference)
+	if err != nil {
+		return 0, err
+	}
+	TransformModel(model, m)
+	return rmsd, nil
+}
+
+// superpose returns the transform that superposes mobile
+// onto reference and the RMSD after it, leaving mobile as is
+func superpose(mobile, reference []fauxgl.Vector) (fauxgl.Matrix, float64, error) {
+	m, err := Kabsch(mobile, reference)
+	if err != nil {
+		return fauxgl.Matrix{}, 0, err
+	}
+	moved := make([]fauxgl.Vector, len(mobile))
+	for i, p := range mobile {
+		moved[i] = m.MulPosition(p)
+	}
+	rmsd, err := RMSD(moved, reference)
+	return m, rmsd, err
+}
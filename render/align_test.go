@@ -0,0 +1,183 @@
+package render
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/ribbon/pdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertVectorsEqual(t *testing.T, expected, actual []fauxgl.Vector) {
+	require.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		assert.InDelta(t, 0, expected[i].Sub(actual[i]).Length(), 1e-6, "point %d", i)
+	}
+}
+
+// rigid is an arbitrary rotation and translation
+var rigid = fauxgl.Translate(fauxgl.Vector{X: 12, Y: -3, Z: 40}).
+	Mul(fauxgl.Rotate(fauxgl.Vector{X: 1, Y: 2, Z: -0.5}, 2.3))
+
+func transformAll(m fauxgl.Matrix, points []fauxgl.Vector) []fauxgl.Vector {
+	moved := make([]fauxgl.Vector, len(points))
+	for i, p := range points {
+		moved[i] = m.MulPosition(p)
+	}
+	return moved
+}
+
+func TestKabsch(t *testing.T) {
+	model, err := ReadModel("testdata/2l0e_minim/2l0e_minim_2.pdb")
+	require.NoError(t, err)
+	target := CAPositions(model)
+	require.Len(t, target, 31)
+	mobile := transformAll(rigid, target)
+	m, err := Kabsch(mobile, target)
+	require.NoError(t, err)
+	aligned := transformAll(m, mobile)
+	assertVectorsEqual(t, target, aligned)
+	rmsd, err := RMSD(aligned, target)
+	require.NoError(t, err)
+	assert.InDelta(t, 0, rmsd, 1e-6)
+	// A proper rotation, not a reflection
+	assert.InDelta(t, 1, m.Determinant(), 1e-9)
+}
+
+func TestKabschMirrored(t *testing.T) {
+	model, err := ReadModel("testdata/2l0e_minim/2l0e_minim_0.pdb")
+	require.NoError(t, err)
+	target := CAPositions(model)
+	// The mirror image of a helix can't be superposed on it
+	mobile := transformAll(fauxgl.Scale(fauxgl.Vector{X: -1, Y: 1, Z: 1}), target)
+	m, err := Kabsch(mobile, target)
+	require.NoError(t, err)
+	assert.InDelta(t, 1, m.Determinant(), 1e-9)
+	rmsd, err := RMSD(transformAll(m, mobile), target)
+	require.NoError(t, err)
+	assert.Greater(t, rmsd, 0.5)
+}
+
+func TestKabschInvalid(t *testing.T) {
+	_, err := Kabsch(nil, nil)
+	require.Error(t, err)
+	_, err = Kabsch(make([]fauxgl.Vector, 2), make([]fauxgl.Vector, 3))
+	require.Error(t, err)
+	_, err = RMSD(make([]fauxgl.Vector, 2), make([]fauxgl.Vector, 3))
+	require.Error(t, err)
+}
+
+func TestRMSD(t *testing.T) {
+	a := []fauxgl.Vector{{X: 0}, {X: 1}}
+	b := []fauxgl.Vector{{X: 3}, {X: 1, Y: 4}}
+	rmsd, err := RMSD(a, b)
+	require.NoError(t, err)
+	assert.InDelta(t, math.Sqrt((9+16)/2.0), rmsd, 1e-12)
+}
+
+func TestCAPositionsSkipsIons(t *testing.T) {
+	model := readFixture(t, 0)
+	// 2l0e has 31 residues, and the fixture some waters
+	expected := CAPositions(model)
+	require.Len(t, expected, 31)
+	// A calcium ion and another water, as GROMACS writes them
+	ion := &pdb.Atom{Name: "CA", ResName: "CA", X: 1, Y: 2, Z: 3}
+	water := &pdb.Atom{Name: "OW", ResName: "SOL", X: 4, Y: 5, Z: 6}
+	for _, a := range []*pdb.Atom{ion, water} {
+		model.Residues = append(model.Residues, &pdb.Residue{
+			ResName:     a.ResName,
+			Atoms:       []*pdb.Atom{a},
+			AtomsByName: map[string]*pdb.Atom{a.Name: a},
+		})
+	}
+	assertVectorsEqual(t, expected, CAPositions(model))
+	assert.Len(t, proteinResidues(model), len(expected))
+}
+
+func TestSuperpose(t *testing.T) {
+	reference, err := ReadModel("testdata/2l0e_minim/2l0e_minim_0.pdb")
+	require.NoError(t, err)
+	model, err := ReadModel("testdata/2l0e_minim/2l0e_minim_0.pdb")
+	require.NoError(t, err)
+	TransformModel(model, rigid)
+	// Waters are moved along with the protein
+	water := atomPosition(model.Atoms[len(model.Atoms)-1])
+	assert.InDelta(t, 0, water.Sub(rigid.MulPosition(atomPosition(reference.Atoms[len(reference.Atoms)-1]))).Length(), 1e-6)
+	rmsd, err := Superpose(model, CAPositions(reference))
+	require.NoError(t, err)
+	assert.InDelta(t, 0, rmsd, 1e-6)
+	for i, a := range model.Atoms {
+		assert.InDelta(t, 0, atomPosition(a).Sub(atomPosition(reference.Atoms[i])).Length(), 1e-6, "atom %d", i)
+	}
+	// A frame that has really moved keeps its deviation
+	bent, err := ReadModel("testdata/2l0e_minim/2l0e_minim_2.pdb")
+	require.NoError(t, err)
+	rmsd, err = Superpose(bent, CAPositions(reference))
+	require.NoError(t, err)
+	assert.Greater(t, rmsd, 0.1)
+}
+
+func TestRendererAlign(t *testing.T) {
+	options := DefaultOptions()
+	options.Size = 48
+	options.Scale = 1
+	r, err := NewRenderer(options)
+	require.NoError(t, err)
+	first, err := ReadModel("testdata/2l0e_minim/2l0e_minim_0.pdb")
+	require.NoError(t, err)
+	expected, err := r.Render(first)
+	require.NoError(t, err)
+	// The same frame after diffusing and tumbling through
+	// the box should be drawn exactly where it was
+	moved, err := ReadModel("testdata/2l0e_minim/2l0e_minim_0.pdb")
+	require.NoError(t, err)
+	TransformModel(moved, rigid)
+	actual, err := r.Render(moved)
+	require.NoError(t, err)
+	assert.InDelta(t, 0, r.RMSD(), 1e-6)
+	require.Equal(t, expected.Bounds(), actual.Bounds())
+	differ := 0
+	b := expected.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r0, g0, b0, _ := expected.At(x, y).RGBA()
+			r1, g1, b1, _ := actual.At(x, y).RGBA()
+			// Allow for rounding at the edges of triangles
+			if absDiff(r0, r1) > 0x800 || absDiff(g0, g1) > 0x800 || absDiff(b0, b1) > 0x800 {
+				differ++
+			}
+		}
+	}
+	assert.Less(t, differ, b.Dx()*b.Dy()/100)
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestRendererSmoothing(t *testing.T) {
+	options := DefaultOptions()
+	options.Align = false
+	options.Smoothing = 1
+	_, err := NewRenderer(options)
+	require.Error(t, err)
+	options.Smoothing = 0.5
+	r, err := NewRenderer(options)
+	require.NoError(t, err)
+	first, err := ReadModel("testdata/2l0e_minim/2l0e_minim_0.pdb")
+	require.NoError(t, err)
+	require.NoError(t, r.prepare(first))
+	second, err := ReadModel("testdata/2l0e_minim/2l0e_minim_2.pdb")
+	require.NoError(t, err)
+	target := atomPosition(second.Atoms[len(second.Atoms)/2])
+	start := atomPosition(first.Atoms[len(first.Atoms)/2])
+	require.NoError(t, r.prepare(second))
+	// Halfway between the previous and the new position
+	actual := atomPosition(second.Atoms[len(second.Atoms)/2])
+	assert.InDelta(t, 0, actual.Sub(start.Add(target).DivScalar(2)).Length(), 1e-9)
+}
@@ -0,0 +1,272 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/fogleman/ease"
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/ribbon/ribbon"
+)
+
+// CameraMode is how the camera moves over a video
+type CameraMode string
+
+const (
+	// CameraFixed keeps the view ribbon picks for the first frame
+	CameraFixed CameraMode = "fixed"
+
+	// CameraOrbit turns the camera around the structure
+	CameraOrbit CameraMode = "orbit"
+
+	// CameraOscillate swings the camera from side to side
+	CameraOscillate CameraMode = "oscillate"
+
+	// CameraReframe follows the structure, easing the view onto
+	// it every so often as it unfolds or drifts out of shot
+	CameraReframe CameraMode = "reframe"
+)
+
+// easings are the fogleman/ease functions a CameraPath may name
+var easings = map[string]ease.Function{
+	"linear":         ease.Linear,
+	"in_quad":        ease.InQuad,
+	"out_quad":       ease.OutQuad,
+	"in_out_quad":    ease.InOutQuad,
+	"in_cubic":       ease.InCubic,
+	"out_cubic":      ease.OutCubic,
+	"in_out_cubic":   ease.InOutCubic,
+	"in_quart":       ease.InQuart,
+	"out_quart":      ease.OutQuart,
+	"in_out_quart":   ease.InOutQuart,
+	"in_sine":        ease.InSine,
+	"out_sine":       ease.OutSine,
+	"in_out_sine":    ease.InOutSine,
+	"in_expo":        ease.InExpo,
+	"out_expo":       ease.OutExpo,
+	"in_out_expo":    ease.InOutExpo,
+	"in_out_circ":    ease.InOutCirc,
+	"in_out_back":    ease.InOutBack,
+	"in_out_elastic": ease.InOutElastic,
+	"out_bounce":     ease.OutBounce,
+}
+
+// ParseEasing returns the easing function with the given
+// name, such as in_out_cubic
+func ParseEasing(name string) (ease.Function, error) {
+	f, ok := easings[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown easing '%s'", name)
+	}
+	return f, nil
+}
+
+// CameraPath is how the camera moves over a video, starting
+// from the view ribbon picks for the first frame. The camera
+// turns about the up axis of that view, through the center of
+// the structure, and widens its view so that the first frame
+// would fit from every angle. Reframing follows a structure
+// that changes shape. The zero value is CameraFixed.
+type CameraPath struct {
+	Mode CameraMode `json:"mode,omitempty"`
+
+	// Frames is how many frames a turn of the orbit or a swing
+	// back and forth takes. If zero, it spans the whole video.
+	// Render, which can't know how long the video is, then
+	// keeps the camera still.
+	Frames int `json:"frames,omitempty"`
+
+	// Degrees is how far the orbit turns in Frames, 360 if zero,
+	// or how far the oscillation swings to either side, 30 if zero
+	Degrees float64 `json:"degrees,omitempty"`
+
+	// Interval is how many frames reframing takes to ease the
+	// view onto the structure, 30 if zero. The view is fit to the
+	// structure at the start of each interval.
+	Interval int `json:"interval,omitempty"`
+
+	// Easing paces each turn of the orbit and each reframing,
+	// linear for the orbit and in_out_cubic for reframing if empty
+	Easing string `json:"easing,omitempty"`
+}
+
+// validate checks the path and fills in its defaults
+func (p CameraPath) validate() (CameraPath, error) {
+	if p.Mode == "" {
+		p.Mode = CameraFixed
+	}
+	p.Mode = CameraMode(strings.ToLower(string(p.Mode)))
+	switch p.Mode {
+	case CameraFixed, CameraOrbit, CameraOscillate, CameraReframe:
+	default:
+		return p, fmt.Errorf("unknown camera mode '%s', expected fixed, orbit, oscillate or reframe", p.Mode)
+	}
+	if p.Frames < 0 {
+		return p, fmt.Errorf("expected non-negative frames, got %d", p.Frames)
+	}
+	if p.Interval < 0 {
+		return p, fmt.Errorf("expected non-negative interval, got %d", p.Interval)
+	}
+	if math.IsNaN(p.Degrees) || math.IsInf(p.Degrees, 0) {
+		return p, fmt.Errorf("expected finite degrees, got %v", p.Degrees)
+	}
+	if p.Degrees == 0 {
+		switch p.Mode {
+		case CameraOrbit:
+			p.Degrees = 360
+		case CameraOscillate:
+			p.Degrees = 30
+		}
+	}
+	if p.Interval == 0 {
+		p.Interval = 30
+	}
+	if p.Easing == "" {
+		p.Easing = "linear"
+		if p.Mode == CameraReframe {
+			p.Easing = "in_out_cubic"
+		}
+	}
+	if _, err := ParseEasing(p.Easing); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// cameraRig places the camera for each frame along a path
+type cameraRig struct {
+	path   CameraPath
+	easing ease.Function
+	// from and to are the views reframing
+	// eases between in the current interval
+	from, to ribbon.Camera
+	// fovy fits the structure from every angle of
+	// an orbit or oscillation, once it is known
+	fovy float64
+}
+
+func newCameraRig(path CameraPath) (*cameraRig, error) {
+	path, err := path.validate()
+	if err != nil {
+		return nil, fmt.Errorf("camera: %v", err)
+	}
+	easing, _ := ParseEasing(path.Easing)
+	return &cameraRig{path: path, easing: easing}, nil
+}
+
+// at returns the camera for the frame at position out of total,
+// given the view of the first frame. mesh is the frame, fit to
+// the view. Reframing carries state from one frame to the next,
+// so frames must be placed in order.
+func (c *cameraRig) at(base ribbon.Camera, mesh *fauxgl.Mesh, position, total int) ribbon.Camera {
+	frames := c.path.Frames
+	if frames == 0 {
+		frames = total
+	}
+	switch c.path.Mode {
+	case CameraOrbit:
+		if frames <= 0 {
+			return base
+		}
+		base.Fovy = c.sweepFovy(base, mesh, 0, c.path.Degrees)
+		turns := float64(position) / float64(frames)
+		whole := math.Floor(turns)
+		return orbitCamera(base, c.path.Degrees*(whole+c.easing(turns-whole)))
+	case CameraOscillate:
+		if frames <= 0 {
+			return base
+		}
+		base.Fovy = c.sweepFovy(base, mesh, -c.path.Degrees, c.path.Degrees)
+		t := float64(position) / float64(frames)
+		return orbitCamera(base, c.path.Degrees*math.Sin(2*math.Pi*t))
+	case CameraReframe:
+		interval := c.path.Interval
+		if position == 0 {
+			c.from, c.to = base, base
+		} else if position%interval == 0 {
+			c.from = c.to
+			c.to = frameCamera(c.to, mesh)
+		}
+		t := float64(position%interval) / float64(interval)
+		return lerpCamera(c.from, c.to, c.easing(t))
+	default:
+		return base
+	}
+}
+
+// sweepFovy returns the field of view that fits mesh as the
+// camera turns from one angle to another, or all the way round,
+// and no narrower than that of camera. It is found for the first
+// mesh and kept, so the view doesn't zoom in and out as it turns.
+func (c *cameraRig) sweepFovy(camera ribbon.Camera, mesh *fauxgl.Mesh, from, to float64) float64 {
+	if c.fovy > 0 {
+		return c.fovy
+	}
+	c.fovy = camera.Fovy
+	if mesh == nil {
+		return c.fovy
+	}
+	if math.Abs(to-from) >= 360 {
+		from, to = 0, 360
+	}
+	const samples = 36
+	for i := 0; i <= samples; i++ {
+		turned := orbitCamera(camera, from+(to-from)*float64(i)/samples)
+		c.fovy = math.Max(c.fovy, fitFovy(turned, mesh))
+	}
+	return c.fovy
+}
+
+// orbitCamera turns camera by degrees about its up
+// axis, through the point it looks at
+func orbitCamera(camera ribbon.Camera, degrees float64) ribbon.Camera {
+	rotation := fauxgl.Rotate(camera.Up, fauxgl.Radians(degrees))
+	camera.Eye = camera.Center.Add(rotation.MulPosition(camera.Eye.Sub(camera.Center)))
+	return camera
+}
+
+// frameCamera aims camera at the center of mesh, from the same
+// direction and distance, with the field of view that fits it
+func frameCamera(camera ribbon.Camera, mesh *fauxgl.Mesh) ribbon.Camera {
+	if len(mesh.Triangles) == 0 {
+		return camera
+	}
+	offset := camera.Eye.Sub(camera.Center)
+	camera.Center = mesh.BoundingBox().Center()
+	camera.Eye = camera.Center.Add(offset)
+	if fovy := fitFovy(camera, mesh); fovy > 0 {
+		camera.Fovy = fovy
+	}
+	return camera
+}
+
+// fitFovy returns the field of view in which camera
+// just sees all of mesh, with ribbon's margin
+func fitFovy(camera ribbon.Camera, mesh *fauxgl.Mesh) float64 {
+	view := fauxgl.LookAt(camera.Eye, camera.Center, camera.Up)
+	// A sample of the vertices is plenty to fit the view
+	stride := len(mesh.Triangles)/4096 + 1
+	extent := 0.0
+	for i := 0; i < len(mesh.Triangles); i += stride {
+		t := mesh.Triangles[i]
+		for _, v := range []fauxgl.Vector{t.V1.Position, t.V2.Position, t.V3.Position} {
+			p := view.MulPosition(v)
+			depth := -p.Z
+			if depth <= 0 {
+				continue
+			}
+			extent = math.Max(extent, math.Abs(p.Y)/depth)
+			extent = math.Max(extent, math.Abs(p.X)/depth/camera.Aspect)
+		}
+	}
+	return fauxgl.Degrees(2*math.Atan(extent)) * 1.1
+}
+
+// lerpCamera returns the view t of the way from a to b
+func lerpCamera(a, b ribbon.Camera, t float64) ribbon.Camera {
+	a.Eye = a.Eye.Add(b.Eye.Sub(a.Eye).MulScalar(t))
+	a.Center = a.Center.Add(b.Center.Sub(a.Center).MulScalar(t))
+	a.Fovy += (b.Fovy - a.Fovy) * t
+	return a
+}
@@ -0,0 +1,162 @@
+package render
+
+import (
+	"image"
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/ribbon/ribbon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCameraPathValidate(t *testing.T) {
+	p, err := CameraPath{}.validate()
+	require.NoError(t, err)
+	assert.Equal(t, CameraFixed, p.Mode)
+	p, err = CameraPath{Mode: "Orbit"}.validate()
+	require.NoError(t, err)
+	assert.Equal(t, CameraOrbit, p.Mode)
+	assert.Equal(t, 360.0, p.Degrees)
+	assert.Equal(t, "linear", p.Easing)
+	p, err = CameraPath{Mode: CameraReframe}.validate()
+	require.NoError(t, err)
+	assert.Equal(t, 30, p.Interval)
+	assert.Equal(t, "in_out_cubic", p.Easing)
+
+	for _, invalid := range []CameraPath{
+		{Mode: "dolly"},
+		{Mode: CameraOrbit, Frames: -1},
+		{Mode: CameraReframe, Interval: -1},
+		{Mode: CameraOrbit, Easing: "wobbly"},
+	} {
+		_, err := invalid.validate()
+		assert.Error(t, err, "%+v", invalid)
+	}
+	options := DefaultOptions()
+	options.Camera.Mode = "dolly"
+	_, err = NewRenderer(options)
+	require.Error(t, err)
+}
+
+// testCamera looks at the origin from 10 units along the x axis
+func testCamera() ribbon.Camera {
+	return ribbon.Camera{
+		Eye:    fauxgl.V(10, 0, 0),
+		Center: fauxgl.V(0, 0, 0),
+		Up:     fauxgl.V(0, 0, 1),
+		Fovy:   20,
+		Aspect: 1,
+	}
+}
+
+func assertVector(t *testing.T, expected, actual fauxgl.Vector, msgAndArgs ...interface{}) {
+	assert.InDelta(t, 0, expected.Distance(actual), 1e-9, msgAndArgs...)
+}
+
+func TestOrbitCamera(t *testing.T) {
+	c := testCamera()
+	assertVector(t, fauxgl.V(0, -10, 0), orbitCamera(c, 90).Eye)
+	assertVector(t, fauxgl.V(-10, 0, 0), orbitCamera(c, 180).Eye)
+	assertVector(t, c.Eye, orbitCamera(c, 360).Eye)
+	assert.Equal(t, c.Center, orbitCamera(c, 90).Center)
+}
+
+func TestCameraRig(t *testing.T) {
+	base := testCamera()
+	rig, err := newCameraRig(CameraPath{Mode: CameraOrbit})
+	require.NoError(t, err)
+	// One turn over the video
+	assertVector(t, base.Eye, rig.at(base, nil, 0, 4).Eye)
+	assertVector(t, fauxgl.V(0, -10, 0), rig.at(base, nil, 1, 4).Eye)
+	assertVector(t, fauxgl.V(-10, 0, 0), rig.at(base, nil, 2, 4).Eye)
+	// Without the length of the video, the camera stays put
+	assertVector(t, base.Eye, rig.at(base, nil, 2, 0).Eye)
+
+	rig, err = newCameraRig(CameraPath{Mode: CameraOrbit, Frames: 2, Degrees: 90, Easing: "in_out_quad"})
+	require.NoError(t, err)
+	assertVector(t, orbitCamera(base, 45).Eye, rig.at(base, nil, 1, 0).Eye)
+	assertVector(t, fauxgl.V(-10, 0, 0), rig.at(base, nil, 4, 0).Eye)
+
+	rig, err = newCameraRig(CameraPath{Mode: CameraOscillate, Frames: 4})
+	require.NoError(t, err)
+	assertVector(t, base.Eye, rig.at(base, nil, 0, 0).Eye)
+	assertVector(t, orbitCamera(base, 30).Eye, rig.at(base, nil, 1, 0).Eye)
+	assertVector(t, orbitCamera(base, -30).Eye, rig.at(base, nil, 3, 0).Eye)
+}
+
+func TestSweepFovy(t *testing.T) {
+	base := testCamera()
+	base.Fovy = 1
+	// A rod pointing at the camera, which looks
+	// longer as the camera turns
+	rod := fauxgl.NewCube()
+	rod.Transform(fauxgl.Scale(fauxgl.V(3, 0.1, 0.1)))
+	rig, err := newCameraRig(CameraPath{Mode: CameraOscillate, Frames: 4, Degrees: 45})
+	require.NoError(t, err)
+	camera := rig.at(base, rod, 0, 0)
+	assert.Greater(t, camera.Fovy, fitFovy(base, rod))
+	assert.InDelta(t, fitFovy(orbitCamera(base, 45), rod), camera.Fovy, 1e-9)
+	// Kept for the frames that follow
+	assert.Equal(t, camera.Fovy, rig.at(base, fauxgl.NewCube(), 1, 0).Fovy)
+}
+
+func TestCameraRigReframe(t *testing.T) {
+	base := testCamera()
+	mesh := fauxgl.NewCube()
+	// The structure drifts away from the center of the view
+	mesh.Transform(fauxgl.Translate(fauxgl.V(0, 3, 1)))
+	rig, err := newCameraRig(CameraPath{Mode: CameraReframe, Interval: 4, Easing: "linear"})
+	require.NoError(t, err)
+	var cameras []ribbon.Camera
+	for i := 0; i < 9; i++ {
+		cameras = append(cameras, rig.at(base, mesh, i, 0))
+	}
+	assert.Equal(t, base, cameras[0])
+	assert.Equal(t, base, cameras[3])
+	// It eases onto the structure over the next interval
+	assertVector(t, fauxgl.V(0, 0.75, 0.25), cameras[5].Center)
+	assertVector(t, fauxgl.V(0, 3, 1), cameras[8].Center)
+	// From the same direction and distance
+	assertVector(t, fauxgl.V(10, 3, 1), cameras[8].Eye)
+	// Closer in, since the cube is smaller than the view
+	assert.Less(t, cameras[8].Fovy, base.Fovy)
+	assert.Greater(t, cameras[8].Fovy, 0.0)
+}
+
+func TestRenderCameraPath(t *testing.T) {
+	// The same structure, which should fit from every angle
+	fixture, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	var frames []Frame
+	for i := 0; i < 6; i++ {
+		frames = append(frames, Frame{Index: i, Path: fixture[0].Path})
+	}
+	options := DefaultOptions()
+	options.Size = 32
+	options.Scale = 1
+	options.Workers = 2
+	options.Camera = CameraPath{Mode: CameraOrbit}
+	r, err := NewRenderer(options)
+	require.NoError(t, err)
+	var images []image.Image
+	err = r.renderParallel(frames, func(_ Frame, img image.Image) error {
+		images = append(images, img)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, images, len(frames))
+
+	// The structure stays in shot all the way round
+	background := options.Background.NRGBA()
+	for i, img := range images {
+		b := img.Bounds()
+		top := image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Min.Y+1)
+		bottom := image.Rect(b.Min.X, b.Max.Y-1, b.Max.X, b.Max.Y)
+		assert.Equal(t, b.Dx(), countColor(img, top, background), "frame %d", i)
+		assert.Equal(t, b.Dx(), countColor(img, bottom, background), "frame %d", i)
+	}
+	// Halfway round, the same structure looks different
+	half := len(frames) / 2
+	assert.NotEqual(t, 0, countDifferent(images[0], images[half]))
+}
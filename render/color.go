@@ -0,0 +1,364 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/ribbon/pdb"
+	"github.com/fogleman/ribbon/ribbon"
+)
+
+// ColorScheme is what the ribbon is colored by
+type ColorScheme string
+
+const (
+	// ColorChain runs along the gradient from the start of
+	// the ribbon to the end. Without a gradient, ribbon colors
+	// each chain from purple to yellow.
+	ColorChain ColorScheme = "chain"
+
+	// ColorHydrophobicity uses the Kyte-Doolittle scale,
+	// from hydrophilic to hydrophobic
+	ColorHydrophobicity ColorScheme = "hydrophobicity"
+
+	// ColorResidueType groups residues as hydrophobic,
+	// polar, positive, negative or special (C, G, P)
+	ColorResidueType ColorScheme = "type"
+
+	// ColorSecondary colors coil, helix and strand
+	ColorSecondary ColorScheme = "secondary"
+
+	// ColorRMSD is the deviation of each residue from the
+	// first frame, or from the reference structure
+	ColorRMSD ColorScheme = "rmsd"
+
+	// ColorBFactor is the temperature factor of each alpha carbon
+	ColorBFactor ColorScheme = "bfactor"
+)
+
+// ParseColorScheme returns the scheme with the given name
+func ParseColorScheme(s string) (ColorScheme, error) {
+	switch c := ColorScheme(strings.ToLower(s)); c {
+	case ColorChain, ColorHydrophobicity, ColorResidueType, ColorSecondary, ColorRMSD, ColorBFactor:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown color scheme '%s', expected chain, hydrophobicity, type, secondary, rmsd or bfactor", s)
+	}
+}
+
+// defaultRMSDRange is the span of the RMSD gradient when
+// none is given, in Ångströms
+const defaultRMSDRange = 3.0
+
+// gradients are the named gradients that ParseGradient accepts
+var gradients = map[string]*ribbon.Colormap{
+	"viridis":  ribbon.Viridis,
+	"magma":    ribbon.Magma,
+	"inferno":  ribbon.Inferno,
+	"plasma":   ribbon.Plasma,
+	"spectral": ribbon.Spectral,
+	"blues":    ribbon.Blues,
+	"viget":    ribbon.Viget,
+	// Blue to orange, for hydrophilic to hydrophobic
+	"hydro": newGradient("2166AC", "F7F7F7", "E08214"),
+	// Blue, white and red, for temperature factors
+	"bwr": newGradient("2166AC", "F7F7F7", "B2182B"),
+}
+
+// defaultGradients are used when Options.Gradient is nil
+var defaultGradients = map[ColorScheme]*ribbon.Colormap{
+	ColorHydrophobicity: gradients["hydro"],
+	ColorResidueType:    ribbon.Spectral,
+	ColorSecondary:      ribbon.Viget,
+	ColorRMSD:           ribbon.Plasma,
+	ColorBFactor:        gradients["bwr"],
+}
+
+func newGradient(colors ...string) *ribbon.Colormap {
+	c := make([]fauxgl.Color, len(colors))
+	for i, s := range colors {
+		c[i] = fauxgl.HexColor(s)
+	}
+	return ribbon.NewColormap(c)
+}
+
+// ParseGradient returns a named gradient, such as viridis,
+// or one made of comma separated RRGGBB colors
+func ParseGradient(s string) (*ribbon.Colormap, error) {
+	if g, ok := gradients[strings.ToLower(s)]; ok {
+		return g, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("unknown gradient '%s', expected a name or at least two colors", s)
+	}
+	colors := make([]fauxgl.Color, len(parts))
+	for i, part := range parts {
+		c, err := ParseColor(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		colors[i] = c
+	}
+	return ribbon.NewColormap(colors), nil
+}
+
+// kyteDoolittle is the hydropathy index of each residue
+var kyteDoolittle = map[string]float64{
+	"ILE": 4.5, "VAL": 4.2, "LEU": 3.8, "PHE": 2.8, "CYS": 2.5,
+	"MET": 1.9, "ALA": 1.8, "GLY": -0.4, "THR": -0.7, "SER": -0.8,
+	"TRP": -0.9, "TYR": -1.3, "PRO": -1.6, "HIS": -3.2, "GLU": -3.5,
+	"GLN": -3.5, "ASP": -3.5, "ASN": -3.5, "LYS": -3.9, "ARG": -4.5,
+}
+
+// residueTypes places each residue on the gradient by group
+var residueTypes = map[string]float64{
+	// Hydrophobic
+	"ALA": 0, "VAL": 0, "LEU": 0, "ILE": 0, "MET": 0, "PHE": 0, "TRP": 0,
+	// Polar
+	"SER": 0.25, "THR": 0.25, "ASN": 0.25, "GLN": 0.25, "TYR": 0.25,
+	// Positive
+	"LYS": 0.5, "ARG": 0.5, "HIS": 0.5,
+	// Negative
+	"ASP": 0.75, "GLU": 0.75,
+	// Special
+	"CYS": 1, "GLY": 1, "PRO": 1,
+}
+
+// residueName strips the protonation state GROMACS
+// adds to some names, e.g. HISE or LYSH
+func residueName(residue *pdb.Residue) string {
+	name := strings.ToUpper(residue.ResName)
+	if len(name) > 3 {
+		name = name[:3]
+	}
+	return name
+}
+
+// secondaryValue places DSSP codes on the gradient as
+// coil (0), helix (0.5) or strand (1)
+func secondaryValue(code byte) float64 {
+	switch code {
+	case 'H', 'G', 'I':
+		return 0.5
+	case 'E', 'B':
+		return 1
+	default:
+		return 0
+	}
+}
+
+func residueTypeValue(t pdb.ResidueType) float64 {
+	switch t {
+	case pdb.ResidueTypeHelix:
+		return 0.5
+	case pdb.ResidueTypeStrand:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// isHydrogen reports whether a is a hydrogen. GROMACS
+// frames leave the element column blank.
+func isHydrogen(a *pdb.Atom) bool {
+	if a.Element != "" {
+		return a.Element == "H"
+	}
+	name := strings.TrimLeft(a.Name, "0123456789")
+	return strings.HasPrefix(name, "H")
+}
+
+// heavyAtoms returns the positions of the heavy atoms of each residue
+func heavyAtoms(residues []*pdb.Residue) []map[string]fauxgl.Vector {
+	atoms := make([]map[string]fauxgl.Vector, len(residues))
+	for i, residue := range residues {
+		atoms[i] = make(map[string]fauxgl.Vector)
+		for _, a := range residue.Atoms {
+			if !isHydrogen(a) {
+				atoms[i][a.Name] = atomPosition(a)
+			}
+		}
+	}
+	return atoms
+}
+
+// residueRMSD returns the RMSD of the atoms of residue that
+// are also in reference, which is zero if there are none
+func residueRMSD(residue *pdb.Residue, reference map[string]fauxgl.Vector) float64 {
+	sum := 0.0
+	n := 0
+	for _, a := range residue.Atoms {
+		if p, ok := reference[a.Name]; ok {
+			d := atomPosition(a).Sub(p)
+			sum += d.Dot(d)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+// proteinResidues returns the amino acids of model, in the
+// same order as CAPositions. These are the residues with a
+// backbone: an alpha carbon alone could be a calcium ion,
+// which GROMACS names CA too.
+func proteinResidues(model *pdb.Model) []*pdb.Residue {
+	var residues []*pdb.Residue
+	for _, residue := range model.Residues {
+		_, n := residue.AtomsByName["N"]
+		_, ca := residue.AtomsByName["CA"]
+		_, c := residue.AtomsByName["C"]
+		if n && ca && c {
+			residues = append(residues, residue)
+		}
+	}
+	return residues
+}
+
+// colorer assigns a color to each residue of a frame
+type colorer struct {
+	scheme    ColorScheme
+	gradient  *ribbon.Colormap
+	min, max  float64
+	fixed     bool // whether min and max were given
+	secondary string
+
+	// reference holds the heavy atoms of each residue
+	// of the structure RMSD is measured against
+	reference []map[string]fauxgl.Vector
+}
+
+func newColorer(options Options) (*colorer, error) {
+	scheme := options.Color
+	if scheme == "" {
+		scheme = ColorChain
+	}
+	if _, err := ParseColorScheme(string(scheme)); err != nil {
+		return nil, err
+	}
+	c := &colorer{
+		scheme:    scheme,
+		gradient:  options.Gradient,
+		min:       options.ColorMin,
+		max:       options.ColorMax,
+		fixed:     options.ColorMin != 0 || options.ColorMax != 0,
+		secondary: options.Secondary,
+	}
+	if c.fixed && c.max <= c.min {
+		return nil, fmt.Errorf("expected color range min < max, got %v and %v", c.min, c.max)
+	}
+	if c.gradient == nil && scheme != ColorChain {
+		c.gradient = defaultGradients[scheme]
+	}
+	if scheme == ColorRMSD && !c.fixed {
+		c.min, c.max, c.fixed = 0, defaultRMSDRange, true
+	}
+	if options.Reference != nil {
+		c.reference = heavyAtoms(proteinResidues(options.Reference))
+	}
+	return c, nil
+}
+
+// values returns the position of each residue on the gradient
+func (c *colorer) values(residues []*pdb.Residue) ([]float64, error) {
+	values := make([]float64, len(residues))
+	switch c.scheme {
+	case ColorHydrophobicity:
+		for i, residue := range residues {
+			values[i] = (kyteDoolittle[residueName(residue)] + 4.5) / 9
+		}
+	case ColorResidueType:
+		for i, residue := range residues {
+			if v, ok := residueTypes[residueName(residue)]; ok {
+				values[i] = v
+			}
+		}
+	case ColorSecondary:
+		if c.secondary != "" && len(c.secondary) != len(residues) {
+			return nil, fmt.Errorf("secondary structure has %d residues, but the model has %d", len(c.secondary), len(residues))
+		}
+		for i, residue := range residues {
+			if c.secondary != "" {
+				values[i] = secondaryValue(c.secondary[i])
+			} else {
+				values[i] = residueTypeValue(residue.Type)
+			}
+		}
+	case ColorRMSD:
+		if c.reference == nil {
+			// The first frame is the reference
+			c.reference = heavyAtoms(residues)
+		}
+		if len(c.reference) != len(residues) {
+			return nil, fmt.Errorf("reference has %d residues, but the model has %d", len(c.reference), len(residues))
+		}
+		for i, residue := range residues {
+			values[i] = c.scale(residueRMSD(residue, c.reference[i]))
+		}
+	case ColorBFactor:
+		if len(values) == 0 {
+			// A frame of ions or ligands alone
+			return values, nil
+		}
+		for i, residue := range residues {
+			values[i] = residue.AtomsByName["CA"].TempFactor
+		}
+		if !c.fixed {
+			// Keep the range of the first frame so
+			// colors mean the same thing throughout
+			c.min, c.max = values[0], values[0]
+			for _, v := range values {
+				c.min = math.Min(c.min, v)
+				c.max = math.Max(c.max, v)
+			}
+			if c.max == c.min {
+				c.max = c.min + 1
+			}
+			c.fixed = true
+		}
+		for i, v := range values {
+			values[i] = c.scale(v)
+		}
+	}
+	return values, nil
+}
+
+func (c *colorer) scale(v float64) float64 {
+	return (v - c.min) / (c.max - c.min)
+}
+
+// needsResidues reports whether apply uses the
+// residue of each triangle
+func (c *colorer) needsResidues() bool {
+	return c.scheme != ColorChain
+}
+
+// apply colors the triangles of the ribbon. residues gives
+// the residue of each triangle, and is nil for ColorChain.
+func (c *colorer) apply(mesh *fauxgl.Mesh, model *pdb.Model, residues []int) error {
+	if c.scheme == ColorChain {
+		if c.gradient == nil {
+			return nil
+		}
+		n := len(mesh.Triangles)
+		for i, t := range mesh.Triangles {
+			t.SetColor(c.gradient.Color(float64(i) / float64(n-1)))
+		}
+		return nil
+	}
+	values, err := c.values(proteinResidues(model))
+	if err != nil {
+		return err
+	}
+	for i, t := range mesh.Triangles {
+		if r := residues[i]; r >= 0 {
+			t.SetColor(c.gradient.Color(values[r]))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/ribbon/pdb"
+	"github.com/fogleman/ribbon/ribbon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColorScheme(t *testing.T) {
+	c, err := ParseColorScheme("RMSD")
+	require.NoError(t, err)
+	assert.Equal(t, ColorRMSD, c)
+	_, err = ParseColorScheme("rainbow")
+	require.Error(t, err)
+	options := DefaultOptions()
+	options.Color = "rainbow"
+	_, err = NewRenderer(options)
+	require.Error(t, err)
+}
+
+func TestParseGradient(t *testing.T) {
+	g, err := ParseGradient("Viridis")
+	require.NoError(t, err)
+	assert.Equal(t, ribbon.Viridis, g)
+	g, err = ParseGradient("000000, FFFFFF")
+	require.NoError(t, err)
+	assert.Equal(t, fauxgl.Black, g.Color(0))
+	assert.Equal(t, fauxgl.White, g.Color(1))
+	for _, s := range []string{"", "rainbow", "000000", "000000,FFFFFG"} {
+		_, err := ParseGradient(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func readFixture(t *testing.T, index int) *pdb.Model {
+	frames, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	model, err := ReadModel(frames[index].Path)
+	require.NoError(t, err)
+	return model
+}
+
+func fixtureValues(t *testing.T, options Options, models ...*pdb.Model) []float64 {
+	c, err := newColorer(options)
+	require.NoError(t, err)
+	var values []float64
+	for _, model := range models {
+		values, err = c.values(proteinResidues(model))
+		require.NoError(t, err)
+	}
+	return values
+}
+
+func TestColorHydrophobicity(t *testing.T) {
+	model := readFixture(t, 0)
+	options := DefaultOptions()
+	options.Color = ColorHydrophobicity
+	values := fixtureValues(t, options, model)
+	// KKKDNLLFGSIISAVDPVAVLAVFEEIHKKK
+	require.Len(t, values, 31)
+	assert.InDelta(t, 0.067, values[0], 0.001)
+	assert.InDelta(t, 1.0, values[10], 1e-9)
+	assert.InDelta(t, (-0.8+4.5)/9, values[9], 1e-9)
+}
+
+func TestColorResidueType(t *testing.T) {
+	model := readFixture(t, 0)
+	options := DefaultOptions()
+	options.Color = ColorResidueType
+	values := fixtureValues(t, options, model)
+	assert.Equal(t, 0.5, values[0])  // LYS
+	assert.Equal(t, 0.75, values[3]) // ASP
+	assert.Equal(t, 0.25, values[4]) // ASN
+	assert.Equal(t, 0.0, values[5])  // LEU
+	assert.Equal(t, 1.0, values[8])  // GLY
+}
+
+func TestColorSecondary(t *testing.T) {
+	model := readFixture(t, 0)
+	options := DefaultOptions()
+	options.Color = ColorSecondary
+	options.Secondary = "CC" + "HHHHHHHHHH" + "EEEEEEEEEE" + "GGGGGGGBC"
+	values := fixtureValues(t, options, model)
+	assert.Equal(t, 0.0, values[0])
+	assert.Equal(t, 0.5, values[2])
+	assert.Equal(t, 1.0, values[12])
+	assert.Equal(t, 0.5, values[22])
+	assert.Equal(t, 1.0, values[29])
+
+	options.Secondary = "HHH"
+	c, err := newColorer(options)
+	require.NoError(t, err)
+	_, err = c.values(proteinResidues(model))
+	require.Error(t, err)
+}
+
+func TestColorRMSD(t *testing.T) {
+	first, last := readFixture(t, 0), readFixture(t, 2)
+	options := DefaultOptions()
+	options.Color = ColorRMSD
+	for _, v := range fixtureValues(t, options, first, first) {
+		assert.Equal(t, 0.0, v)
+	}
+	// The last frame is bent, so some residues move
+	moved := 0.0
+	for _, v := range fixtureValues(t, options, first, last) {
+		moved = math.Max(moved, v)
+	}
+	assert.Greater(t, moved, 0.0)
+
+	// A reference is used instead of the first frame
+	options.Reference = last
+	for _, v := range fixtureValues(t, options, last) {
+		assert.Equal(t, 0.0, v)
+	}
+}
+
+func TestColorBFactor(t *testing.T) {
+	model := readFixture(t, 0)
+	for i, residue := range proteinResidues(model) {
+		residue.AtomsByName["CA"].TempFactor = float64(10 + i)
+	}
+	options := DefaultOptions()
+	options.Color = ColorBFactor
+	values := fixtureValues(t, options, model)
+	assert.Equal(t, 0.0, values[0])
+	assert.Equal(t, 1.0, values[30])
+	assert.InDelta(t, 0.5, values[15], 1e-9)
+
+	options.ColorMin, options.ColorMax = 10, 50
+	values = fixtureValues(t, options, model)
+	assert.InDelta(t, 0.5, values[20], 1e-9)
+
+	options.ColorMin, options.ColorMax = 50, 10
+	_, err := newColorer(options)
+	require.Error(t, err)
+}
+
+func TestRenderBFactorWithoutProtein(t *testing.T) {
+	// Only the waters of the fixture and a calcium ion
+	model := readFixture(t, 0)
+	var residues []*pdb.Residue
+	for _, residue := range model.Residues {
+		if residue.ResName == "SOL" {
+			residues = append(residues, residue)
+		}
+	}
+	require.NotEmpty(t, residues)
+	ion := &pdb.Atom{Name: "CA", ResName: "CA", X: 1, Y: 2, Z: 3}
+	residues = append(residues, &pdb.Residue{
+		ResName:     "CA",
+		Atoms:       []*pdb.Atom{ion},
+		AtomsByName: map[string]*pdb.Atom{"CA": ion},
+	})
+	var atoms []*pdb.Atom
+	for _, residue := range residues {
+		atoms = append(atoms, residue.Atoms...)
+	}
+	model.Residues, model.Atoms, model.Chains = residues, atoms, nil
+	require.Empty(t, proteinResidues(model))
+
+	options := DefaultOptions()
+	options.Size = 64
+	options.Scale = 1
+	options.Color = ColorBFactor
+	// There is nothing to align either
+	options.Align = false
+	r, err := NewRenderer(options)
+	require.NoError(t, err)
+	pinCamera(t, r)
+	_, err = r.Render(model)
+	require.NoError(t, err)
+}
+
+func TestRenderColorSchemes(t *testing.T) {
+	render := func(scheme ColorScheme) image.Image {
+		options := DefaultOptions()
+		options.Size = 64
+		options.Scale = 1
+		options.Color = scheme
+		r, err := NewRenderer(options)
+		require.NoError(t, err)
+		img, err := r.Render(readFixture(t, 0))
+		require.NoError(t, err)
+		return img
+	}
+	images := map[ColorScheme]image.Image{}
+	for _, scheme := range []ColorScheme{ColorChain, ColorHydrophobicity, ColorResidueType} {
+		images[scheme] = render(scheme)
+	}
+	assert.Greater(t, countDifferent(images[ColorChain], images[ColorHydrophobicity]), 0)
+	assert.Greater(t, countDifferent(images[ColorHydrophobicity], images[ColorResidueType]), 0)
+}
+
+// countDifferent returns the number of pixels that differ between a and b
+func countDifferent(a, b image.Image) int {
+	n := 0
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if color.NRGBAModel.Convert(a.At(x, y)) != color.NRGBAModel.Convert(b.At(x, y)) {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,375 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/ribbon/pdb"
+	"github.com/fogleman/ribbon/ribbon"
+)
+
+// CompareMode is how trajectories are shown together
+type CompareMode string
+
+const (
+	// CompareSuperposed draws the trajectories over each
+	// other, each in a color of its own
+	CompareSuperposed CompareMode = "superposed"
+
+	// CompareTiled draws each trajectory in a tile of its
+	// own, side by side, seen from the same camera
+	CompareTiled CompareMode = "tiled"
+)
+
+// ParseCompareMode returns the mode with the given name
+func ParseCompareMode(s string) (CompareMode, error) {
+	switch m := CompareMode(strings.ToLower(s)); m {
+	case CompareSuperposed, CompareTiled:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown compare mode '%s', expected superposed or tiled", s)
+	}
+}
+
+// compareColors tell superposed trajectories apart
+var compareColors = []fauxgl.Color{
+	fauxgl.HexColor("4C9BE8"),
+	fauxgl.HexColor("F2994A"),
+	fauxgl.HexColor("6FCF97"),
+	fauxgl.HexColor("BB6BD9"),
+	fauxgl.HexColor("F2C94C"),
+	fauxgl.HexColor("56CCF2"),
+}
+
+// Trajectory is one of the structures being compared
+type Trajectory struct {
+	// Name labels the trajectory, e.g. "seed 1"
+	Name string
+
+	// Frames are the steps of the trajectory. A shorter
+	// trajectory holds its last frame while the others go on.
+	Frames []Frame
+
+	// Model is a fixed structure, such as the reference
+	// PDB, shown at every step. It is used if Frames is empty.
+	Model *pdb.Model
+}
+
+// CompareOptions control how trajectories are shown together
+type CompareOptions struct {
+	Mode CompareMode
+
+	// Colors are the colors of the superposed trajectories,
+	// in order. They replace Options.Color. If there are too
+	// few, a palette of distinct colors is used.
+	Colors []fauxgl.Color
+
+	// Columns is the number of tiles in each row
+	// when tiled, or all in one row if zero
+	Columns int
+}
+
+// DefaultCompareOptions superposes the trajectories
+func DefaultCompareOptions() CompareOptions {
+	return CompareOptions{Mode: CompareSuperposed}
+}
+
+// comparison draws several trajectories step by step. Every
+// trajectory is aligned to the same reference, the first frame
+// of the first trajectory unless Options.Reference is set, and
+// seen from the camera positioned for the first step, which
+// moves along Options.Camera with the first trajectory.
+type comparison struct {
+	trajectories []Trajectory
+	renderers    []*Renderer
+	colors       []fauxgl.Color
+	compare      CompareOptions
+	steps        int
+}
+
+func newComparison(trajectories []Trajectory, options Options, compare CompareOptions) (*comparison, error) {
+	if len(trajectories) < 2 {
+		return nil, fmt.Errorf("expected at least two trajectories, got %d", len(trajectories))
+	}
+	if _, err := ParseCompareMode(string(compare.Mode)); err != nil {
+		return nil, err
+	}
+	if compare.Columns < 0 {
+		return nil, fmt.Errorf("expected non-negative columns, got %d", compare.Columns)
+	}
+	c := &comparison{
+		trajectories: trajectories,
+		compare:      compare,
+		steps:        1,
+	}
+	for i, t := range trajectories {
+		if len(t.Frames) == 0 && t.Model == nil {
+			return nil, fmt.Errorf("trajectory %d has neither frames nor a model", i)
+		}
+		if len(t.Frames) > c.steps {
+			c.steps = len(t.Frames)
+		}
+		tint := compareColors[i%len(compareColors)]
+		if i < len(compare.Colors) {
+			tint = compare.Colors[i]
+		}
+		c.colors = append(c.colors, tint)
+	}
+	if options.Reference == nil {
+		// Read separately, since frames are moved as they are drawn
+		reference, err := c.model(0, 0)
+		if err != nil {
+			return nil, err
+		}
+		options.Reference = reference
+	}
+	for i, t := range trajectories {
+		o := options
+		switch compare.Mode {
+		case CompareSuperposed:
+			o.Color = ColorChain
+			o.Gradient = ribbon.NewColormap([]fauxgl.Color{c.colors[i], c.colors[i]})
+		case CompareTiled:
+			o.Overlay.ID = strings.TrimSpace(o.Overlay.ID + " " + t.Name)
+		}
+		r, err := NewRenderer(o)
+		if err != nil {
+			return nil, err
+		}
+		c.renderers = append(c.renderers, r)
+	}
+	return c, nil
+}
+
+// model reads trajectory t at step i
+func (c *comparison) model(t, i int) (*pdb.Model, error) {
+	frames := c.trajectories[t].Frames
+	if len(frames) == 0 {
+		return c.trajectories[t].Model, nil
+	}
+	if i >= len(frames) {
+		i = len(frames) - 1
+	}
+	return ReadModel(frames[i].Path)
+}
+
+// step is the step shown for i, taken from
+// the first trajectory that has a frame there
+func (c *comparison) step(i int) int {
+	for _, t := range c.trajectories {
+		if i < len(t.Frames) {
+			return t.Frames[i].Index
+		}
+	}
+	return i
+}
+
+// build builds step i of every trajectory. It returns the
+// function that draws it and an estimate of its memory.
+func (c *comparison) build(i int) (drawFunc, int64, error) {
+	switch c.compare.Mode {
+	case CompareTiled:
+		return c.buildTiled(i)
+	default:
+		return c.buildSuperposed(i)
+	}
+}
+
+func (c *comparison) buildSuperposed(i int) (drawFunc, int64, error) {
+	combined := fauxgl.NewEmptyMesh()
+	var first *pdb.Model
+	var reference []fauxgl.Vector
+	var labels []string
+	for t, r := range c.renderers {
+		model, err := c.model(t, i)
+		if err != nil {
+			return nil, 0, err
+		}
+		mesh, err := r.buildMesh(model)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s step %d: %v", c.name(t), i, err)
+		}
+		combined.Add(mesh)
+		// Each trajectory is labeled with its
+		// RMSD from the first at this step
+		label := c.name(t)
+		cas := CAPositions(model)
+		if t == 0 {
+			first, reference = model, cas
+		} else if _, rmsd, err := superpose(cas, reference); err == nil {
+			label += fmt.Sprintf(" (%.2f Å)", rmsd)
+		}
+		labels = append(labels, label)
+	}
+	r := c.renderers[0]
+	r.fit(first, combined)
+	f := r.record(combined, c.step(i))
+	colors := make([]color.Color, len(c.colors))
+	for t, tint := range c.colors {
+		colors[t] = tint.NRGBA()
+	}
+	draw := func(dc *fauxgl.Context) image.Image {
+		frame := toRGBA(r.draw(dc, f))
+		drawKey(frame, labels, colors, textColor(r.options.Background))
+		return frame
+	}
+	return draw, r.frameBytes(f), nil
+}
+
+func (c *comparison) buildTiled(i int) (drawFunc, int64, error) {
+	frames := make([]*builtFrame, len(c.renderers))
+	var bytes int64
+	for t, r := range c.renderers {
+		model, err := c.model(t, i)
+		if err != nil {
+			return nil, 0, err
+		}
+		if first := c.renderers[0]; t > 0 && r.camera == nil {
+			// Synchronize the camera with the first tile
+			r.camera, r.matrix = first.camera, first.matrix
+		}
+		f, err := r.build(model, c.step(i))
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s step %d: %v", c.name(t), i, err)
+		}
+		if t > 0 {
+			// Reframing follows the first tile too
+			f.camera = frames[0].camera
+		}
+		frames[t] = f
+		bytes += r.frameBytes(f)
+	}
+	draw := func(dc *fauxgl.Context) image.Image {
+		tiles := make([]image.Image, len(frames))
+		for t, f := range frames {
+			tiles[t] = c.renderers[t].draw(dc, f)
+		}
+		return tile(tiles, c.compare.Columns, c.renderers[0].options.Background.NRGBA())
+	}
+	return draw, bytes, nil
+}
+
+func (c *comparison) name(t int) string {
+	if name := c.trajectories[t].Name; name != "" {
+		return name
+	}
+	return fmt.Sprintf("trajectory %d", t+1)
+}
+
+// render draws every step and passes each image to emit in order
+func (c *comparison) render(emit func(step int, img image.Image) error) error {
+	for t, r := range c.renderers {
+		r.total = c.steps
+		if t > 0 && c.compare.Mode == CompareSuperposed {
+			// Only the first renderer draws
+			break
+		}
+		if frames := c.trajectories[t].Frames; r.options.Overlay.Sparkline && len(frames) > 0 {
+			series, err := trajectoryRMSD(frames, r.reference)
+			if err != nil {
+				return err
+			}
+			r.series = series
+		}
+	}
+	first, bytes, err := c.build(0)
+	if err != nil {
+		return err
+	}
+	r := c.renderers[0]
+	workers := renderWorkers(r.options.Workers, r.options.MemoryLimit, r.workerBytes(), bytes)
+	return runPipeline(c.steps, workers, r.newContext, first, func(i int) (drawFunc, error) {
+		draw, _, err := c.build(i)
+		return draw, err
+	}, func(i int, img image.Image) error {
+		return emit(c.step(i), img)
+	})
+}
+
+// tile lays images out in a grid, left to right and top to
+// bottom, with columns in each row or all in one row if zero
+func tile(images []image.Image, columns int, background color.Color) *image.RGBA {
+	if columns <= 0 || columns > len(images) {
+		columns = len(images)
+	}
+	rows := (len(images) + columns - 1) / columns
+	size := images[0].Bounds().Size()
+	dst := image.NewRGBA(image.Rect(0, 0, columns*size.X, rows*size.Y))
+	draw.Draw(dst, dst.Rect, image.NewUniform(background), image.Point{}, draw.Src)
+	for i, img := range images {
+		p := image.Pt(i%columns*size.X, i/columns*size.Y)
+		draw.Draw(dst, image.Rectangle{Min: p, Max: p.Add(size)}, img, img.Bounds().Min, draw.Src)
+	}
+	return dst
+}
+
+// drawKey draws a swatch of each color next to its
+// label, down from the top right corner of dst
+func drawKey(dst draw.Image, labels []string, colors []color.Color, textColor color.Color) {
+	b := dst.Bounds()
+	scale := overlayScale(b)
+	margin := 4 * scale
+	width := 0
+	for _, label := range labels {
+		if w := textSize(label, scale).X; w > width {
+			width = w
+		}
+	}
+	swatch := textFace.Height * scale
+	x := b.Max.X - margin - width - swatch - 2*scale
+	for i, label := range labels {
+		y := b.Min.Y + margin + i*(textFace.Height+1)*scale
+		square := image.Rect(x, y, x+swatch, y+swatch)
+		draw.Draw(dst, square, image.NewUniform(colors[i]), image.Point{}, draw.Src)
+		drawText(dst, image.Pt(square.Max.X+2*scale, y), scale, label, textColor)
+	}
+}
+
+// CompareFrames draws the trajectories together at each
+// step to <dir>/compare_<step>.png
+func CompareFrames(trajectories []Trajectory, dir string, options Options, compare CompareOptions) error {
+	c, err := newComparison(trajectories, options, compare)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return c.render(func(step int, img image.Image) error {
+		path := filepath.Join(dir, fmt.Sprintf("compare_%d.png", step))
+		if err := fauxgl.SavePNG(path, img); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		return nil
+	})
+}
+
+// CompareVideo draws the trajectories together
+// straight into a video at path
+func CompareVideo(trajectories []Trajectory, path string, options Options, compare CompareOptions, video VideoOptions) (err error) {
+	c, err := newComparison(trajectories, options, compare)
+	if err != nil {
+		return err
+	}
+	e, err := CreateVideo(path, video)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return c.render(func(step int, img image.Image) error {
+		if err := e.Encode(img); err != nil {
+			return fmt.Errorf("step %d: %v", step, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,156 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCompareMode(t *testing.T) {
+	m, err := ParseCompareMode("Tiled")
+	require.NoError(t, err)
+	assert.Equal(t, CompareTiled, m)
+	_, err = ParseCompareMode("stacked")
+	require.Error(t, err)
+}
+
+func TestNewComparisonInvalid(t *testing.T) {
+	frames, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	options := DefaultOptions()
+	trajectory := Trajectory{Frames: frames}
+	_, err = newComparison([]Trajectory{trajectory}, options, DefaultCompareOptions())
+	require.Error(t, err, "one trajectory")
+	_, err = newComparison([]Trajectory{trajectory, {}}, options, DefaultCompareOptions())
+	require.Error(t, err, "empty trajectory")
+	_, err = newComparison([]Trajectory{trajectory, trajectory}, options, CompareOptions{Mode: "stacked"})
+	require.Error(t, err)
+	_, err = newComparison([]Trajectory{trajectory, trajectory}, options, CompareOptions{Mode: CompareTiled, Columns: -1})
+	require.Error(t, err)
+}
+
+func TestTile(t *testing.T) {
+	images := []image.Image{
+		solid(4, 3, color.Black),
+		solid(4, 3, color.White),
+		solid(4, 3, color.RGBA{R: 255, A: 255}),
+	}
+	background := color.RGBA{B: 255, A: 255}
+	img := tile(images, 2, background)
+	assert.Equal(t, image.Rect(0, 0, 8, 6), img.Bounds())
+	assert.Equal(t, color.RGBA{A: 255}, img.RGBAAt(1, 1))
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, img.RGBAAt(5, 1))
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, img.RGBAAt(1, 4))
+	assert.Equal(t, background, img.RGBAAt(5, 4))
+	assert.Equal(t, image.Rect(0, 0, 12, 3), tile(images, 0, background).Bounds())
+}
+
+// compare renders trajectories and returns the image of each step
+func compare(t *testing.T, trajectories []Trajectory, options Options, compare CompareOptions) ([]int, []image.Image) {
+	c, err := newComparison(trajectories, options, compare)
+	require.NoError(t, err)
+	var steps []int
+	var images []image.Image
+	require.NoError(t, c.render(func(step int, img image.Image) error {
+		steps = append(steps, step)
+		images = append(images, img)
+		return nil
+	}))
+	return steps, images
+}
+
+func TestCompareSuperposed(t *testing.T) {
+	frames, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	reference, err := ReadModel(frames[0].Path)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.Size = 64
+	options.Scale = 1
+	steps, images := compare(t, []Trajectory{
+		{Name: "seed 1", Frames: frames},
+		{Name: "seed 2", Frames: frames[:2]},
+		{Name: "reference", Model: reference},
+	}, options, DefaultCompareOptions())
+	assert.Equal(t, []int{0, 1, 2}, steps)
+	for i, img := range images {
+		// The key has a swatch of each color
+		b := img.Bounds()
+		key := image.Rect(b.Max.X/2, 0, b.Max.X, b.Max.Y/2)
+		for _, c := range compareColors[:3] {
+			assert.Greater(t, countColor(img, key, c.NRGBA()), 0, "step %d", i)
+		}
+	}
+}
+
+func TestCompareTiled(t *testing.T) {
+	frames, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.Size = 32
+	options.Scale = 1
+	options.Workers = 2
+	trajectories := []Trajectory{
+		{Frames: frames[:2]},
+		{Frames: frames},
+	}
+	_, images := compare(t, trajectories, options, CompareOptions{Mode: CompareTiled})
+	require.Len(t, images, 3)
+	b := images[0].Bounds()
+	half := b.Dx() / 2
+	assert.Equal(t, 32, b.Dy())
+	// The tiles share a camera, so the same frame looks the same
+	// in both. The first trajectory holds its last frame.
+	for i, differ := range []bool{false, false, true} {
+		left := image.NewRGBA(image.Rect(0, 0, half, b.Dy()))
+		right := image.NewRGBA(image.Rect(0, 0, half, b.Dy()))
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < half; x++ {
+				left.Set(x, y, images[i].At(x, y))
+				right.Set(x, y, images[i].At(half+x, y))
+			}
+		}
+		if differ {
+			assert.Greater(t, countDifferent(left, right), 0, "step %d", i)
+		} else {
+			assert.Equal(t, 0, countDifferent(left, right), "step %d", i)
+		}
+	}
+}
+
+func TestCompareVideo(t *testing.T) {
+	frames, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "compare-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	options := DefaultOptions()
+	options.Size = 32
+	options.Scale = 1
+	video := DefaultVideoOptions()
+	video.Format = GIF
+	path := filepath.Join(dir, "compare.gif")
+	trajectories := []Trajectory{{Frames: frames}, {Frames: frames}}
+	require.NoError(t, CompareVideo(trajectories, path, options, CompareOptions{Mode: CompareTiled, Columns: 1}, video))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	require.NoError(t, err)
+	require.Len(t, g.Image, len(frames))
+	assert.Equal(t, 64, g.Image[0].Bounds().Dy())
+
+	require.NoError(t, CompareFrames(trajectories, filepath.Join(dir, "png"), options, DefaultCompareOptions()))
+	for _, frame := range frames {
+		_, err := os.Stat(filepath.Join(dir, "png", fmt.Sprintf("compare_%d.png", frame.Index)))
+		assert.NoError(t, err)
+	}
+}
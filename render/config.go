@@ -0,0 +1,40 @@
+package render
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is a render config file, which describes the parts of
+// a render that are easier to write down than pass as flags:
+//
+//	camera:
+//	  mode: orbit
+//	  degrees: 360
+//	  easing: in_out_sine
+type Config struct {
+	Camera CameraPath `json:"camera,omitempty"`
+}
+
+// LoadConfig reads a render config from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if _, err := config.Camera.validate(); err != nil {
+		return nil, fmt.Errorf("%s: camera: %v", path, err)
+	}
+	return config, nil
+}
+
+// Apply sets the options the config covers
+func (c *Config) Apply(options *Options) {
+	options.Camera = c.Camera
+}
@@ -0,0 +1,41 @@
+package render
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "render.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`camera:
+  mode: oscillate
+  frames: 120
+  degrees: 45
+`), 0644))
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, CameraPath{Mode: CameraOscillate, Frames: 120, Degrees: 45}, config.Camera)
+	options := DefaultOptions()
+	config.Apply(&options)
+	assert.Equal(t, config.Camera, options.Camera)
+
+	for _, invalid := range []string{
+		"camera:\n  mode: dolly\n",
+		"camera:\n  mode: orbit\n  speed: 2\n",
+		"camera: [orbit]\n",
+	} {
+		require.NoError(t, ioutil.WriteFile(path, []byte(invalid), 0644))
+		_, err = LoadConfig(path)
+		assert.Error(t, err, invalid)
+	}
+	_, err = LoadConfig(filepath.Join(dir, "missing.yaml"))
+	require.Error(t, err)
+}
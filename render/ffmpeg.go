@@ -0,0 +1,100 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+// ffmpegEncoder pipes raw frames to an ffmpeg subprocess,
+// which is started when the size of the first frame is known
+type ffmpegEncoder struct {
+	path    string
+	options VideoOptions
+	size    image.Point
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stderr  bytes.Buffer
+}
+
+func newFFmpegEncoder(path string, options VideoOptions) *ffmpegEncoder {
+	return &ffmpegEncoder{path: path, options: options}
+}
+
+// crf maps quality onto the constant rate factor of the
+// codec, where lower values give larger, better videos
+func crf(format Format, quality int) int {
+	max := 51 // libx264
+	if format == WebM {
+		max = 63 // libvpx-vp9
+	}
+	return max * (100 - quality) / 100
+}
+
+// ffmpegArgs returns the arguments to encode frames of the
+// given size, read as raw RGBA from stdin, to path
+func ffmpegArgs(path string, size image.Point, options VideoOptions) []string {
+	args := []string{
+		"-y",
+		"-loglevel", "error",
+		"-f", "rawvideo",
+		"-pix_fmt", "rgba",
+		"-s", fmt.Sprintf("%dx%d", size.X, size.Y),
+		"-framerate", strconv.Itoa(options.FrameRate),
+		"-i", "-",
+		// yuv420p needs even dimensions
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2",
+		"-pix_fmt", "yuv420p",
+		"-crf", strconv.Itoa(crf(options.Format, options.Quality)),
+	}
+	switch options.Format {
+	case WebM:
+		args = append(args, "-c:v", "libvpx-vp9", "-b:v", "0", "-f", "webm")
+	default:
+		args = append(args, "-c:v", "libx264", "-movflags", "+faststart", "-f", "mp4")
+	}
+	return append(args, path)
+}
+
+func (e *ffmpegEncoder) start() error {
+	e.cmd = exec.Command("ffmpeg", ffmpegArgs(e.path, e.size, e.options)...)
+	e.cmd.Stderr = &e.stderr
+	stdin, err := e.cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	e.stdin = stdin
+	if err := e.cmd.Start(); err != nil {
+		return fmt.Errorf("ffmpeg: %v", err)
+	}
+	return nil
+}
+
+func (e *ffmpegEncoder) Encode(img image.Image) error {
+	if err := checkSize(&e.size, img); err != nil {
+		return err
+	}
+	if e.cmd == nil {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	if _, err := e.stdin.Write(toRGBA(img).Pix); err != nil {
+		return fmt.Errorf("ffmpeg: %v: %s", err, e.stderr.String())
+	}
+	return nil
+}
+
+func (e *ffmpegEncoder) Close() error {
+	if e.cmd == nil {
+		return fmt.Errorf("no frames were encoded")
+	}
+	e.stdin.Close()
+	if err := e.cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg: %v: %s", err, e.stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package render
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// framePattern matches the frames written by the
+// operator, e.g. 2l0e_minim_12.pdb
+var framePattern = regexp.MustCompile(`^(.+)_minim_(\d+)\.pdb$`)
+
+// Frame is a single step of a trajectory
+type Frame struct {
+	// Name is the prefix of the file, usually the PDB ID
+	Name  string
+	Index int
+	Path  string
+}
+
+// FindFrames returns the *_minim_N.pdb frames in dir
+// and its subdirectories, ordered by step
+func FindFrames(dir string) ([]Frame, error) {
+	var frames []Frame
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		m := framePattern.FindStringSubmatch(info.Name())
+		if m == nil {
+			return nil
+		}
+		index, err := strconv.Atoi(m[2])
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		frames = append(frames, Frame{Name: m[1], Index: index, Path: path})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no frames found in %s", dir)
+	}
+	sort.Slice(frames, func(i, j int) bool {
+		return frames[i].Index < frames[j].Index
+	})
+	return frames, nil
+}
+
+// ExtractFrames unpacks the frames of a result tarball
+// into dir and returns them ordered by step
+func ExtractFrames(tarball string, dir string) ([]Frame, error) {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %v", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("tar: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := filepath.Base(header.Name)
+		if !framePattern.MatchString(name) {
+			continue
+		}
+		if err := extractFile(tr, filepath.Join(dir, name)); err != nil {
+			return nil, err
+		}
+	}
+	return FindFrames(dir)
+}
+
+func extractFile(r io.Reader, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return f.Close()
+}
+
+// LoadFrames returns the frames of a result tarball or a
+// directory of frames. Tarballs are extracted to a temporary
+// directory, which is removed by calling cleanup.
+func LoadFrames(path string) (frames []Frame, cleanup func(), err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		frames, err := FindFrames(path)
+		return frames, func() {}, err
+	}
+	if !strings.HasSuffix(path, ".tar.gz") && !strings.HasSuffix(path, ".tgz") {
+		return nil, nil, fmt.Errorf("expected a directory or .tar.gz, got %s", path)
+	}
+	dir, err := ioutil.TempDir("", "frames-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	frames, err = ExtractFrames(path, dir)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return frames, cleanup, nil
+}
+
+// RenderFrames draws each frame to <dir>/<name>_<index>.png
+func RenderFrames(frames []Frame, dir string, options Options) error {
+	r, err := NewRenderer(options)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return r.renderParallel(frames, func(frame Frame, img image.Image) error {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%d.png", frame.Name, frame.Index))
+		if err := fauxgl.SavePNG(path, img); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		return nil
+	})
+}
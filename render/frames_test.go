@@ -0,0 +1,113 @@
+package render
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindFrames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frames-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"1abc_minim_10.pdb", "1abc_minim_2.pdb", "1abc_minim_0.pdb", "1abc_minim.gro", "notes.txt"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	frames, err := FindFrames(dir)
+	require.NoError(t, err)
+	require.Len(t, frames, 3)
+	for i, index := range []int{0, 2, 10} {
+		assert.Equal(t, "1abc", frames[i].Name)
+		assert.Equal(t, index, frames[i].Index)
+		assert.Equal(t, filepath.Join(dir, fmt.Sprintf("1abc_minim_%d.pdb", index)), frames[i].Path)
+	}
+}
+
+func TestFindFramesEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frames-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	_, err = FindFrames(dir)
+	require.Error(t, err)
+}
+
+// writeTarball packs files into a result tarball the way
+// the operator does, under a <pdb>_minim/ directory
+func writeTarball(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name:     "2l0e_minim/",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+	}))
+	for name, body := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(body)),
+		}))
+		_, err := tw.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+}
+
+func TestLoadFramesTarball(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frames-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tarball := filepath.Join(dir, "result.tar.gz")
+	writeTarball(t, tarball, map[string]string{
+		"2l0e_minim/2l0e_minim_1.pdb": "one",
+		"2l0e_minim/2l0e_minim_0.pdb": "zero",
+		"2l0e_minim/2l0e_minim.log":   "log",
+		// Entries are flattened, so this can't escape
+		"../2l0e_minim_2.pdb": "two",
+	})
+	frames, cleanup, err := LoadFrames(tarball)
+	require.NoError(t, err)
+	require.Len(t, frames, 3)
+	for i, body := range []string{"zero", "one", "two"} {
+		assert.Equal(t, i, frames[i].Index)
+		data, err := ioutil.ReadFile(frames[i].Path)
+		require.NoError(t, err)
+		assert.Equal(t, body, string(data))
+	}
+	_, err = os.Stat(filepath.Join(filepath.Dir(dir), "2l0e_minim_2.pdb"))
+	assert.True(t, os.IsNotExist(err))
+	extracted := filepath.Dir(frames[0].Path)
+	cleanup()
+	_, err = os.Stat(extracted)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRenderFrames(t *testing.T) {
+	frames, cleanup, err := LoadFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	defer cleanup()
+	require.Len(t, frames, 3)
+	dir, err := ioutil.TempDir("", "png-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	options := DefaultOptions()
+	options.Size = 32
+	options.Scale = 1
+	require.NoError(t, RenderFrames(frames, dir, options))
+	for i := range frames {
+		_, err := os.Stat(filepath.Join(dir, fmt.Sprintf("2l0e_%d.png", i)))
+		assert.NoError(t, err)
+	}
+}
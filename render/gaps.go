@@ -0,0 +1,211 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/thavlik/foldy-operator/proteinnet"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// textFace is the font of the legend, the overlay and the
+// compare key. It covers Latin-1, so Å is drawn as such.
+var textFace = basicfont.Face7x13
+
+const (
+	// connectorRadius is the radius of the dashes
+	// bridging a gap, in Ångströms
+	connectorRadius = 0.2
+
+	// markerRadius is the radius of the spheres marking the
+	// alpha carbons next to a gap, in Ångströms
+	markerRadius = 0.6
+
+	// dashLength and dashSpace are the lengths of each dash
+	// and of the space after it, in Ångströms
+	dashLength = 1.0
+	dashSpace  = 0.7
+)
+
+// gapHighlight is where a chain is incomplete, in terms of
+// the residues that are in the structure
+type gapHighlight struct {
+	// flanking holds the residues next to a gap
+	flanking map[int]bool
+
+	// bridges are the pairs of residues either side
+	// of an internal gap
+	bridges [][2]int
+
+	missing int
+	gaps    int
+}
+
+func validateMask(mask string) error {
+	for i := 0; i < len(mask); i++ {
+		if mask[i] != proteinnet.Known && mask[i] != proteinnet.Missing {
+			return fmt.Errorf("unexpected mask character '%c' at %d", mask[i], i)
+		}
+	}
+	return nil
+}
+
+// newGapHighlight maps the gaps of a ProteinNet mask onto the
+// residues left in the structure, which are the known ones
+func newGapHighlight(mask string) *gapHighlight {
+	// known[i] is the index in the structure of mask[i]
+	known := make([]int, len(mask)+1)
+	n := 0
+	for i := 0; i < len(mask); i++ {
+		known[i] = n
+		if mask[i] == proteinnet.Known {
+			n++
+		}
+	}
+	known[len(mask)] = n
+	h := &gapHighlight{flanking: make(map[int]bool)}
+	for _, gap := range proteinnet.Gaps(mask) {
+		h.missing += gap.Len()
+		h.gaps++
+		// The residue before the gap, if there is one, is the
+		// last known residue, and the one after is the next
+		before, after := known[gap.Start]-1, known[gap.End]
+		if before >= 0 {
+			h.flanking[before] = true
+		}
+		if after < n {
+			h.flanking[after] = true
+		}
+		if gap.Kind == proteinnet.InternalGap {
+			h.bridges = append(h.bridges, [2]int{before, after})
+		}
+	}
+	return h
+}
+
+// apply colors the triangles of the flanking residues and
+// adds dashed connectors across internal gaps. The ribbon
+// leaves out the residues at the very ends of a chain, which
+// are often next to a gap, so every flanking alpha carbon is
+// also marked with a sphere.
+func (h *gapHighlight) apply(mesh *fauxgl.Mesh, cas []fauxgl.Vector, residues []int, c fauxgl.Color) {
+	if len(h.flanking) > 0 {
+		for i, t := range mesh.Triangles {
+			if h.flanking[residues[i]] {
+				t.SetColor(c)
+			}
+		}
+	}
+	for i := range h.flanking {
+		marker := fauxgl.NewSphere(2)
+		marker.SmoothNormals()
+		marker.Transform(fauxgl.Scale(fauxgl.V(markerRadius, markerRadius, markerRadius)).Translate(cas[i]))
+		marker.SetColor(c)
+		mesh.Add(marker)
+	}
+	for _, bridge := range h.bridges {
+		connector := dashedConnector(cas[bridge[0]], cas[bridge[1]])
+		connector.SetColor(c)
+		mesh.Add(connector)
+	}
+}
+
+// dashedConnector returns a dashed line of cylinders from p0
+// to p1. The dashes are stretched a little so that the line
+// starts and ends with one.
+func dashedConnector(p0, p1 fauxgl.Vector) *fauxgl.Mesh {
+	mesh := fauxgl.NewEmptyMesh()
+	length := p0.Distance(p1)
+	if length == 0 {
+		return mesh
+	}
+	dir := p1.Sub(p0).DivScalar(length)
+	n := math.Max(1, math.Round((length+dashSpace)/(dashLength+dashSpace)))
+	stretch := length / (n*(dashLength+dashSpace) - dashSpace)
+	for i := 0.0; i < n; i++ {
+		start := i * (dashLength + dashSpace) * stretch
+		end := start + dashLength*stretch
+		a := p0.Add(dir.MulScalar(start))
+		b := p0.Add(dir.MulScalar(end))
+		dash := fauxgl.NewCylinder(30, true)
+		dash.Transform(fauxgl.Orient(a.Add(b).DivScalar(2), fauxgl.V(connectorRadius, connectorRadius, end-start), dir, 0))
+		mesh.Add(dash)
+	}
+	return mesh
+}
+
+// legend describes the highlighting, e.g. "12 missing residues in 3 gaps"
+func (h *gapHighlight) legend() string {
+	residues := "residues"
+	if h.missing == 1 {
+		residues = "residue"
+	}
+	gaps := "gaps"
+	if h.gaps == 1 {
+		gaps = "gap"
+	}
+	return fmt.Sprintf("%d missing %s in %d %s", h.missing, residues, h.gaps, gaps)
+}
+
+// textColor returns black or white, whichever
+// stands out more against background
+func textColor(background fauxgl.Color) color.Color {
+	luma := 0.299*background.R + 0.587*background.G + 0.114*background.B
+	if luma > 0.5 {
+		return color.Black
+	}
+	return color.White
+}
+
+// drawLegend draws a swatch of the highlight color and
+// the legend in the bottom left corner of dst
+func drawLegend(dst draw.Image, text string, swatch, textColor color.Color) {
+	b := dst.Bounds()
+	scale := b.Dy() / 256
+	if scale < 1 {
+		scale = 1
+	}
+	margin := 4 * scale
+	size := textSize(text, scale)
+	y := b.Max.Y - margin - size.Y
+	square := image.Rect(b.Min.X+margin, y, b.Min.X+margin+size.Y, y+size.Y)
+	draw.Draw(dst, square, image.NewUniform(swatch), image.Point{}, draw.Src)
+	drawText(dst, image.Pt(square.Max.X+2*scale, y), scale, text, textColor)
+}
+
+// textSize returns the size of s when drawn at scale
+func textSize(s string, scale int) image.Point {
+	if s == "" {
+		return image.Point{}
+	}
+	width := font.MeasureString(textFace, s).Ceil()
+	return image.Pt(width*scale, textFace.Height*scale)
+}
+
+// drawText draws s with its top left corner at p. Each pixel
+// of the font becomes a scale by scale square.
+func drawText(dst draw.Image, p image.Point, scale int, s string, c color.Color) {
+	size := textSize(s, 1)
+	if size.X == 0 {
+		return
+	}
+	mask := image.NewAlpha(image.Rectangle{Max: size})
+	d := font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: textFace,
+		Dot:  fixed.P(0, textFace.Ascent),
+	}
+	d.DrawString(s)
+	r := image.Rectangle{Min: p, Max: p.Add(size.Mul(scale))}
+	scaled := image.NewAlpha(image.Rectangle{Max: r.Size()})
+	xdraw.NearestNeighbor.Scale(scaled, scaled.Bounds(), mask, mask.Bounds(), draw.Src, nil)
+	draw.DrawMask(dst, r, image.NewUniform(c), image.Point{}, scaled, image.Point{}, draw.Over)
+}
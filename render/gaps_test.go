@@ -0,0 +1,187 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gappedMask has the 31 residues of the fixture, with
+// a gap at each end and one in the middle
+var gappedMask = "-" + strings.Repeat("+", 10) + "---" + strings.Repeat("+", 21) + "-"
+
+func TestGapHighlight(t *testing.T) {
+	h := newGapHighlight(gappedMask)
+	assert.Equal(t, map[int]bool{0: true, 9: true, 10: true, 30: true}, h.flanking)
+	assert.Equal(t, [][2]int{{9, 10}}, h.bridges)
+	assert.Equal(t, "5 missing residues in 3 gaps", h.legend())
+
+	h = newGapHighlight("++-++")
+	assert.Equal(t, map[int]bool{1: true, 2: true}, h.flanking)
+	assert.Equal(t, "1 missing residue in 1 gap", h.legend())
+
+	h = newGapHighlight("+++")
+	assert.Empty(t, h.flanking)
+	assert.Empty(t, h.bridges)
+
+	h = newGapHighlight("---")
+	assert.Empty(t, h.flanking)
+	assert.Empty(t, h.bridges)
+}
+
+func TestValidateMask(t *testing.T) {
+	require.NoError(t, validateMask(gappedMask))
+	require.Error(t, validateMask("++x-"))
+	options := DefaultOptions()
+	options.Mask = "+?"
+	_, err := NewRenderer(options)
+	require.Error(t, err)
+}
+
+func TestResidueLocator(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func(spread float64) fauxgl.Vector {
+		return fauxgl.Vector{X: rng.Float64() * spread, Y: rng.Float64() * spread, Z: rng.Float64() * spread}
+	}
+	points := make([]fauxgl.Vector, 200)
+	for i := range points {
+		points[i] = random(60)
+	}
+	l := newResidueLocator(points)
+	// Queries both near the points and far outside them
+	for _, spread := range []float64{60, 500} {
+		for i := 0; i < 500; i++ {
+			p := random(spread)
+			best := 0
+			for j, q := range points {
+				if q.Sub(p).Length() < points[best].Sub(p).Length() {
+					best = j
+				}
+			}
+			assert.Equal(t, best, l.nearest(p))
+		}
+	}
+	assert.Equal(t, -1, newResidueLocator(nil).nearest(fauxgl.Vector{}))
+}
+
+func TestDashedConnector(t *testing.T) {
+	p0 := fauxgl.Vector{X: 1, Y: 2, Z: 3}
+	p1 := fauxgl.Vector{X: 1, Y: 2, Z: 8}
+	mesh := dashedConnector(p0, p1)
+	single := len(fauxgl.NewCylinder(30, true).Triangles)
+	assert.Len(t, mesh.Triangles, 3*single)
+	// The dashes reach both ends
+	box := mesh.BoundingBox()
+	assert.InDelta(t, 3, box.Min.Z, 1e-9)
+	assert.InDelta(t, 8, box.Max.Z, 1e-9)
+	assert.Empty(t, dashedConnector(p0, p0).Triangles)
+}
+
+// countHighlighted returns the number of pixels that
+// are mostly red, which the viridis ribbon never is
+func countHighlighted(img image.Image) int {
+	n := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if int(c.R) > int(c.G)+60 && int(c.R) > int(c.B)+60 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestRenderGaps(t *testing.T) {
+	model, err := ReadModel("testdata/2l0e_minim/2l0e_minim_0.pdb")
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.Size = 64
+	options.Scale = 2
+	r, err := NewRenderer(options)
+	require.NoError(t, err)
+	plain, err := r.Render(model)
+	require.NoError(t, err)
+	assert.Equal(t, 0, countHighlighted(plain))
+
+	options.Mask = gappedMask
+	r, err = NewRenderer(options)
+	require.NoError(t, err)
+	highlighted, err := r.Render(model)
+	require.NoError(t, err)
+	assert.Greater(t, countHighlighted(highlighted), 0)
+
+	options.Mask = gappedMask + "+"
+	r, err = NewRenderer(options)
+	require.NoError(t, err)
+	_, err = r.Render(model)
+	require.Error(t, err, "the mask has one known residue too many")
+}
+
+func TestRenderLegend(t *testing.T) {
+	model, err := ReadModel("testdata/2l0e_minim/2l0e_minim_0.pdb")
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.Size = 64
+	options.Scale = 1
+	options.Mask = gappedMask
+	options.Legend = true
+	r, err := NewRenderer(options)
+	require.NoError(t, err)
+	img, err := r.Render(model)
+	require.NoError(t, err)
+	// The swatch sits in the bottom left corner
+	b := img.Bounds()
+	swatch := color.NRGBAModel.Convert(img.At(b.Min.X+5, b.Max.Y-6)).(color.NRGBA)
+	assert.Equal(t, options.GapColor.NRGBA(), swatch)
+	white := 0
+	for y := b.Max.Y - 11; y < b.Max.Y-4; y++ {
+		for x := b.Min.X + 13; x < b.Max.X; x++ {
+			if color.NRGBAModel.Convert(img.At(x, y)) == color.NRGBAModel.Convert(color.White) {
+				white++
+			}
+		}
+	}
+	assert.Greater(t, white, 50)
+}
+
+func TestTextSize(t *testing.T) {
+	assert.Equal(t, image.Point{}, textSize("", 3))
+	assert.Equal(t, image.Pt(21, 13), textSize("abc", 1))
+	assert.Equal(t, image.Pt(42, 26), textSize("abc", 2))
+}
+
+func TestDrawText(t *testing.T) {
+	draw := func(s string, scale int) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+		drawText(img, image.Pt(1, 1), scale, s, color.White)
+		return img
+	}
+	// Drawn within the size it measures
+	img := draw("T1", 2)
+	size := textSize("T1", 2)
+	inside := image.Rectangle{Min: image.Pt(1, 1), Max: image.Pt(1, 1).Add(size)}
+	lit := 0
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			if img.RGBAAt(x, y).R == 0 {
+				continue
+			}
+			lit++
+			assert.True(t, image.Pt(x, y).In(inside), "pixel %d,%d", x, y)
+		}
+	}
+	assert.NotZero(t, lit)
+	// Each pixel of the font is scaled up to a square
+	assert.Equal(t, draw("T1", 1).RGBAAt(3, 4), img.RGBAAt(5, 7))
+	// Å has a glyph of its own, unlike characters outside Latin-1
+	assert.NotEqual(t, draw("A", 1).Pix, draw("Å", 1).Pix)
+	assert.Equal(t, draw("�", 1).Pix, draw("λ", 1).Pix)
+}
@@ -0,0 +1,172 @@
+package render
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"io"
+)
+
+// gifEncoder writes an animated GIF one frame at a time.
+// image/gif can only encode a whole animation at once,
+// which would keep every frame of a long trajectory in
+// memory. Frames share the Plan 9 palette, so a single
+// global color table is written with the header.
+type gifEncoder struct {
+	w      *bufio.Writer
+	delay  int
+	drawer draw.Drawer
+	size   image.Point
+	frame  *image.Paletted
+	err    error
+}
+
+func newGIFEncoder(w io.Writer, options VideoOptions) *gifEncoder {
+	var drawer draw.Drawer = draw.Src
+	if options.Quality >= 50 {
+		drawer = draw.FloydSteinberg
+	}
+	// The delay is in hundredths of a second
+	delay := (100 + options.FrameRate/2) / options.FrameRate
+	if delay < 2 {
+		// Browsers slow down shorter delays
+		delay = 2
+	}
+	return &gifEncoder{
+		w:      bufio.NewWriter(w),
+		delay:  delay,
+		drawer: drawer,
+	}
+}
+
+func (e *gifEncoder) write(data ...interface{}) {
+	for _, v := range data {
+		if e.err != nil {
+			return
+		}
+		switch v := v.(type) {
+		case []byte:
+			_, e.err = e.w.Write(v)
+		case string:
+			_, e.err = e.w.WriteString(v)
+		default:
+			e.err = binary.Write(e.w, binary.LittleEndian, v)
+		}
+	}
+}
+
+func (e *gifEncoder) writeHeader() {
+	e.write("GIF89a",
+		uint16(e.size.X), uint16(e.size.Y),
+		// Global color table of 256 entries, 8 bits per channel
+		[]byte{0xf7, 0, 0})
+	table := make([]byte, 0, 3*256)
+	for _, c := range palette.Plan9 {
+		r, g, b, _ := c.RGBA()
+		table = append(table, byte(r>>8), byte(g>>8), byte(b>>8))
+	}
+	e.write(table)
+	// Loop forever
+	e.write([]byte{0x21, 0xff, 11}, "NETSCAPE2.0", []byte{3, 1, 0, 0, 0})
+}
+
+func (e *gifEncoder) Encode(img image.Image) error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := checkSize(&e.size, img); err != nil {
+		return err
+	}
+	if e.frame == nil {
+		if e.size.X > 0xffff || e.size.Y > 0xffff {
+			return fmt.Errorf("frame is too large for a GIF")
+		}
+		e.frame = image.NewPaletted(image.Rect(0, 0, e.size.X, e.size.Y), palette.Plan9)
+		e.writeHeader()
+	}
+	e.drawer.Draw(e.frame, e.frame.Rect, img, img.Bounds().Min)
+	// Graphic control extension with the frame delay
+	e.write([]byte{0x21, 0xf9, 4, 0}, uint16(e.delay), []byte{0, 0})
+	// Image descriptor using the global color table
+	e.write([]byte{0x2c}, uint16(0), uint16(0), uint16(e.size.X), uint16(e.size.Y), []byte{0})
+	const litWidth = 8
+	e.write([]byte{litWidth})
+	if e.err != nil {
+		return e.err
+	}
+	bw := &blockWriter{w: e.w}
+	lw := lzw.NewWriter(bw, lzw.LSB, litWidth)
+	if _, err := lw.Write(e.frame.Pix); err != nil {
+		e.err = err
+		return err
+	}
+	if err := lw.Close(); err != nil {
+		e.err = err
+		return err
+	}
+	if e.err = bw.close(); e.err != nil {
+		return e.err
+	}
+	return nil
+}
+
+func (e *gifEncoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.frame == nil {
+		return fmt.Errorf("no frames were encoded")
+	}
+	e.write([]byte{0x3b})
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// blockWriter splits image data into the sub-blocks of
+// at most 255 bytes that GIF requires
+type blockWriter struct {
+	w   io.Writer
+	buf [256]byte
+	n   int
+}
+
+func (b *blockWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		m := copy(b.buf[1+b.n:], p)
+		b.n += m
+		p = p[m:]
+		written += m
+		if b.n == 255 {
+			if err := b.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (b *blockWriter) flush() error {
+	if b.n == 0 {
+		return nil
+	}
+	b.buf[0] = byte(b.n)
+	_, err := b.w.Write(b.buf[:1+b.n])
+	b.n = 0
+	return err
+}
+
+// close writes the last block and the block terminator
+func (b *blockWriter) close() error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	_, err := b.w.Write([]byte{0})
+	return err
+}
@@ -0,0 +1,162 @@
+package render
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/stretchr/testify/require"
+)
+
+// updateGolden regenerates the goldens after an intended change
+// to how frames look:
+//
+//	go test ./render -run TestRenderGolden -update
+var updateGolden = flag.Bool("update", false, "regenerate the golden images in testdata/golden")
+
+const (
+	// goldenPixelTolerance is how far apart two pixels may be,
+	// by pixelDistance, before they count as different
+	goldenPixelTolerance = 0.05
+
+	// goldenMaxDifferent is the fraction of pixels that may
+	// differ, for the odd edge antialiased another way
+	goldenMaxDifferent = 0.002
+)
+
+// pixelDistance is how different two colors look, from 0 to 1.
+// It weights the channels roughly as the eye does, by the
+// "redmean" approximation.
+func pixelDistance(a, b color.Color) float64 {
+	p := color.NRGBAModel.Convert(a).(color.NRGBA)
+	q := color.NRGBAModel.Convert(b).(color.NRGBA)
+	rmean := (float64(p.R) + float64(q.R)) / 2
+	dr := float64(p.R) - float64(q.R)
+	dg := float64(p.G) - float64(q.G)
+	db := float64(p.B) - float64(q.B)
+	d := (2+rmean/256)*dr*dr + 4*dg*dg + (2+(255-rmean)/256)*db*db
+	return math.Sqrt(d) / (3 * 255)
+}
+
+// compareImages returns the number of pixels that look different
+// and an image of them, in red over a faded copy of expected
+func compareImages(expected, actual image.Image) (int, *image.RGBA) {
+	b := expected.Bounds()
+	diff := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	different := 0
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			e := expected.At(b.Min.X+x, b.Min.Y+y)
+			a := actual.At(actual.Bounds().Min.X+x, actual.Bounds().Min.Y+y)
+			if d := pixelDistance(e, a); d > goldenPixelTolerance {
+				different++
+				diff.SetRGBA(x, y, color.RGBA{R: uint8(128 + 127*d), A: 255})
+				continue
+			}
+			gray := color.GrayModel.Convert(e).(color.Gray)
+			diff.SetRGBA(x, y, color.RGBA{R: gray.Y / 3, G: gray.Y / 3, B: gray.Y / 3, A: 255})
+		}
+	}
+	return different, diff
+}
+
+// assertGolden checks img against testdata/golden/<name>.png,
+// or replaces the golden with it when run with -update. On a
+// mismatch, img and an image of the difference are written to
+// a temporary directory for inspection.
+func assertGolden(t *testing.T, name string, img image.Image) {
+	path := filepath.Join("testdata", "golden", name+".png")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, fauxgl.SavePNG(path, img))
+		return
+	}
+	golden, err := fauxgl.LoadImage(path)
+	require.NoError(t, err, "run the tests with -update to create the golden")
+	if golden.Bounds().Size() != img.Bounds().Size() {
+		t.Fatalf("%s: expected %v, got %v", name, golden.Bounds().Size(), img.Bounds().Size())
+	}
+	different, diff := compareImages(golden, img)
+	b := golden.Bounds()
+	if limit := int(goldenMaxDifferent * float64(b.Dx()*b.Dy())); different > limit {
+		dir, err := ioutil.TempDir("", "golden-")
+		require.NoError(t, err)
+		require.NoError(t, fauxgl.SavePNG(filepath.Join(dir, name+".png"), img))
+		require.NoError(t, fauxgl.SavePNG(filepath.Join(dir, name+"_diff.png"), diff))
+		t.Errorf("%s: %d pixels differ from %s, more than %d. The image and the difference are in %s. If the change is intended, run the tests with -update.", name, different, path, limit, dir)
+	}
+}
+
+func TestCompareImages(t *testing.T) {
+	a := solid(8, 8, color.Black)
+	b := toRGBA(solid(8, 8, color.Black))
+	different, _ := compareImages(a, b)
+	require.Equal(t, 0, different)
+	// Too slight to see
+	b.Set(1, 1, color.RGBA{R: 4, G: 4, B: 4, A: 255})
+	different, _ = compareImages(a, b)
+	require.Equal(t, 0, different)
+	b.Set(2, 2, color.White)
+	different, diff := compareImages(a, b)
+	require.Equal(t, 1, different)
+	// Marked in red, the brighter the more different
+	marked := diff.RGBAAt(2, 2)
+	require.Greater(t, marked.R, uint8(250))
+	require.Zero(t, marked.G)
+}
+
+// renderGolden draws the first n frames of the fixture from
+// fixtureCamera and returns the last
+func renderGolden(t *testing.T, options Options, n int) image.Image {
+	frames, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	r, err := NewRenderer(options)
+	require.NoError(t, err)
+	pinCamera(t, r)
+	var last image.Image
+	err = r.renderParallel(frames[:n], func(_ Frame, img image.Image) error {
+		last = img
+		return nil
+	})
+	require.NoError(t, err)
+	return last
+}
+
+func TestRenderGolden(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		frames int
+		modify func(*Options)
+	}{
+		{"chain", 1, func(*Options) {}},
+		{"hydrophobicity", 1, func(o *Options) {
+			o.Color = ColorHydrophobicity
+		}},
+		{"gaps", 1, func(o *Options) {
+			o.Mask = gappedMask
+			o.Legend = true
+		}},
+		{"overlay", 3, func(o *Options) {
+			o.Smoothing = 0.5
+			o.Overlay = Overlay{ID: "2l0e_1_A", Step: true, RMSD: true, Sparkline: true}
+		}},
+		{"orbit", 2, func(o *Options) {
+			o.Camera = CameraPath{Mode: CameraOrbit}
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			options := DefaultOptions()
+			options.Size = 64
+			options.Scale = 2
+			options.Workers = 2
+			c.modify(&options)
+			assertGolden(t, c.name, renderGolden(t, options, c.frames))
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// markerColor marks the current frame on the sparkline
+var markerColor = color.NRGBA{R: 0xFF, G: 0xC8, B: 0x57, A: 0xFF}
+
+// Overlay is the text and chart drawn over each frame.
+// The zero value draws nothing.
+type Overlay struct {
+	// ID names the structure, e.g. the ProteinNet ID 2l0e_1_A
+	ID string
+
+	// Step shows the index of the frame
+	Step bool
+
+	// TimeStep is the simulated time between frames in
+	// picoseconds. If positive, the elapsed time is shown.
+	TimeStep float64
+
+	// RMSD shows the CA RMSD from the first frame, or
+	// from Options.Reference, after superposition
+	RMSD bool
+
+	// Energies are the potential energy of each step in
+	// kJ/mol, e.g. from ReadXVG. They are shown for the
+	// steps they cover.
+	Energies []float64
+
+	// Sparkline charts the RMSD over the trajectory in the
+	// bottom right corner, marking the current frame.
+	// RenderFrames and RenderVideo read every frame before
+	// drawing the first, to chart the whole trajectory.
+	// Render charts the frames drawn so far.
+	Sparkline bool
+}
+
+func (o Overlay) enabled() bool {
+	return o.ID != "" || o.Step || o.TimeStep > 0 || o.RMSD || len(o.Energies) > 0 || o.Sparkline
+}
+
+func (o Overlay) needsRMSD() bool {
+	return o.RMSD || o.Sparkline
+}
+
+// lines returns the text shown for a frame
+func (o Overlay) lines(step int, rmsd float64) []string {
+	var lines []string
+	if o.ID != "" {
+		lines = append(lines, o.ID)
+	}
+	if o.Step {
+		lines = append(lines, fmt.Sprintf("step %d", step))
+	}
+	if o.TimeStep > 0 {
+		lines = append(lines, "t = "+formatTime(float64(step)*o.TimeStep))
+	}
+	if o.RMSD {
+		lines = append(lines, fmt.Sprintf("RMSD %.2f Å", rmsd))
+	}
+	if step >= 0 && step < len(o.Energies) {
+		lines = append(lines, fmt.Sprintf("E = %.1f kJ/mol", o.Energies[step]))
+	}
+	return lines
+}
+
+// formatTime formats a time in picoseconds with
+// whichever of fs, ps or ns suits it
+func formatTime(ps float64) string {
+	switch {
+	case ps != 0 && math.Abs(ps) < 1:
+		return strconv.FormatFloat(ps*1000, 'g', 4, 64) + " fs"
+	case math.Abs(ps) >= 1000:
+		return strconv.FormatFloat(ps/1000, 'g', 4, 64) + " ns"
+	default:
+		return strconv.FormatFloat(ps, 'g', 4, 64) + " ps"
+	}
+}
+
+// ReadXVG reads the first data column of a GROMACS .xvg
+// file, such as the potential energy from gmx energy
+func ReadXVG(path string) ([]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var values []float64
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == '@' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected a time and a value", path, n)
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		values = append(values, v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return values, nil
+}
+
+// trajectoryRMSD returns the CA RMSD of each frame from
+// reference after superposition, or from the first
+// frame if reference is nil
+func trajectoryRMSD(frames []Frame, reference []fauxgl.Vector) ([]float64, error) {
+	rmsds := make([]float64, len(frames))
+	for i, frame := range frames {
+		model, err := ReadModel(frame.Path)
+		if err != nil {
+			return nil, err
+		}
+		cas := CAPositions(model)
+		if reference == nil {
+			reference = cas
+			continue
+		}
+		if _, rmsds[i], err = superpose(cas, reference); err != nil {
+			return nil, fmt.Errorf("frame %d: %v", frame.Index, err)
+		}
+	}
+	return rmsds, nil
+}
+
+// overlayScale is the size of each pixel of the font,
+// which grows with the frame like the legend
+func overlayScale(b image.Rectangle) int {
+	if scale := b.Dy() / 256; scale > 1 {
+		return scale
+	}
+	return 1
+}
+
+// drawLines draws lines of text down from the top left corner
+func drawLines(dst draw.Image, lines []string, c color.Color) {
+	b := dst.Bounds()
+	scale := overlayScale(b)
+	margin := 4 * scale
+	for i, line := range lines {
+		y := b.Min.Y + margin + i*(textFace.Height+1)*scale
+		drawText(dst, image.Pt(b.Min.X+margin, y), scale, line, c)
+	}
+}
+
+// drawSparkline charts values in the bottom right corner, with
+// the value at current marked. The chart starts from zero.
+func drawSparkline(dst draw.Image, values []float64, current int, c, marker color.Color) {
+	if len(values) == 0 {
+		return
+	}
+	b := dst.Bounds()
+	scale := overlayScale(b)
+	margin := 4 * scale
+	width := 64 * scale
+	if max := b.Dx()/3 - margin; width > max {
+		width = max
+	}
+	height := 16 * scale
+	if width < 2 {
+		return
+	}
+	chart := image.Rect(b.Max.X-margin-width, b.Max.Y-margin-height, b.Max.X-margin, b.Max.Y-margin)
+	drawText(dst, image.Pt(chart.Min.X, chart.Min.Y-(textFace.Height+1)*scale), scale, "RMSD", c)
+	top := 0.0
+	for _, v := range values {
+		top = math.Max(top, v)
+	}
+	if top == 0 {
+		top = 1
+	}
+	point := func(i int) image.Point {
+		x := chart.Min.X
+		if len(values) > 1 {
+			x += i * (chart.Dx() - scale) / (len(values) - 1)
+		}
+		y := chart.Max.Y - scale - int(values[i]/top*float64(chart.Dy()-scale))
+		return image.Pt(x, y)
+	}
+	// The baseline, then the values over it
+	baseline := image.Rect(chart.Min.X, chart.Max.Y-scale, chart.Max.X, chart.Max.Y)
+	draw.Draw(dst, baseline, image.NewUniform(withAlpha(c, 0x60)), image.Point{}, draw.Over)
+	for i := 1; i < len(values); i++ {
+		drawLine(dst, point(i-1), point(i), scale, c)
+	}
+	if current >= 0 && current < len(values) {
+		p := point(current)
+		dot := image.Rect(p.X-scale, p.Y-scale, p.X+2*scale, p.Y+2*scale)
+		draw.Draw(dst, dot, image.NewUniform(marker), image.Point{}, draw.Over)
+	}
+}
+
+// drawLine draws a line of width by width squares from p0 to p1
+func drawLine(dst draw.Image, p0, p1 image.Point, width int, c color.Color) {
+	src := image.NewUniform(c)
+	d := p1.Sub(p0)
+	steps := d.X
+	if steps < 0 {
+		steps = -steps
+	}
+	if dy := d.Y; dy > steps || -dy > steps {
+		if dy < 0 {
+			dy = -dy
+		}
+		steps = dy
+	}
+	for i := 0; i <= steps; i++ {
+		p := p0
+		if steps > 0 {
+			p = p0.Add(image.Pt(d.X*i/steps, d.Y*i/steps))
+		}
+		draw.Draw(dst, image.Rect(p.X, p.Y, p.X+width, p.Y+width), src, image.Point{}, draw.Over)
+	}
+}
+
+// withAlpha returns c with its opacity scaled to a out of 255
+func withAlpha(c color.Color, a uint8) color.Color {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(int(n.A) * int(a) / 0xFF)
+	return n
+}
@@ -0,0 +1,179 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/ribbon/ribbon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayLines(t *testing.T) {
+	var o Overlay
+	assert.False(t, o.enabled())
+	assert.Empty(t, o.lines(3, 1.5))
+	o = Overlay{
+		ID:       "2l0e_1_A",
+		Step:     true,
+		TimeStep: 0.0002,
+		RMSD:     true,
+		Energies: []float64{-100, -120.25},
+	}
+	assert.True(t, o.enabled())
+	assert.Equal(t, []string{
+		"2l0e_1_A",
+		"step 1",
+		"t = 0.2 fs",
+		"RMSD 1.50 Å",
+		"E = -120.2 kJ/mol",
+	}, o.lines(1, 1.5))
+	// There is no energy past the end of the file
+	assert.Len(t, o.lines(2, 1.5), 4)
+}
+
+func TestFormatTime(t *testing.T) {
+	for ps, expected := range map[float64]string{
+		0:      "0 ps",
+		0.0002: "0.2 fs",
+		0.5:    "500 fs",
+		2.5:    "2.5 ps",
+		1500:   "1.5 ns",
+	} {
+		assert.Equal(t, expected, formatTime(ps), "%v", ps)
+	}
+}
+
+func TestReadXVG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xvg-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "energy.xvg")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`# This file was created by gmx energy
+@    title "GROMACS Energies"
+@    xaxis  label "Time (ps)"
+@ s0 legend "Potential"
+    0.000000  -412345.125000
+    1.000000  -412400.500000
+
+    2.000000  -412410.000000
+`), 0644))
+	values, err := ReadXVG(path)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{-412345.125, -412400.5, -412410}, values)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("0.0 -1\n1.0\n"), 0644))
+	_, err = ReadXVG(path)
+	require.Error(t, err)
+	require.NoError(t, ioutil.WriteFile(path, []byte("0.0 abc\n"), 0644))
+	_, err = ReadXVG(path)
+	require.Error(t, err)
+}
+
+func TestTrajectoryRMSD(t *testing.T) {
+	frames, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	rmsds, err := trajectoryRMSD(frames, nil)
+	require.NoError(t, err)
+	require.Len(t, rmsds, len(frames))
+	assert.Equal(t, 0.0, rmsds[0])
+	assert.Greater(t, rmsds[2], 0.0)
+
+	// The same as measured while rendering, with or without Align
+	for _, align := range []bool{true, false} {
+		options := DefaultOptions()
+		options.Size = 16
+		options.Scale = 1
+		options.Align = align
+		options.Overlay.RMSD = true
+		r, err := NewRenderer(options)
+		require.NoError(t, err)
+		for i, frame := range frames {
+			model, err := ReadModel(frame.Path)
+			require.NoError(t, err)
+			_, err = r.Render(model)
+			require.NoError(t, err)
+			assert.InDelta(t, rmsds[i], r.RMSD(), 1e-9, "frame %d", i)
+		}
+	}
+}
+
+// countColor returns the number of pixels of img within r that are c
+func countColor(img image.Image, r image.Rectangle, c color.Color) int {
+	n := 0
+	target := color.NRGBAModel.Convert(c)
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if color.NRGBAModel.Convert(img.At(x, y)) == target {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// fixtureCamera is close to the view ribbon picks for the first
+// frame of the fixture. ribbon places the camera from a random
+// sample of the atoms, so the size of each frame and where things
+// land in it vary from one renderer to the next.
+var fixtureCamera = ribbon.Camera{
+	Eye:    fauxgl.V(-2, -9.8, 0),
+	Center: fauxgl.V(0, 0, 0.05),
+	Up:     fauxgl.V(-0.98, 0.2, 0),
+	Fovy:   1.45,
+	Aspect: 10.3,
+}
+
+// pinCamera makes r draw the fixture from fixtureCamera,
+// for tests that depend on where things are drawn
+func pinCamera(t *testing.T, r *Renderer) {
+	camera := fixtureCamera
+	r.camera, r.matrix = &camera, ribbon.ModelMesh(readFixture(t, 0)).BiUnitCube()
+}
+
+func TestRenderOverlay(t *testing.T) {
+	frames, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.Size = 64
+	options.Scale = 1
+	r, err := NewRenderer(options)
+	require.NoError(t, err)
+	pinCamera(t, r)
+	var plain image.Image
+	err = r.renderParallel(frames[:1], func(_ Frame, img image.Image) error {
+		plain = img
+		return nil
+	})
+	require.NoError(t, err)
+	b := plain.Bounds()
+	corner := image.Rect(0, 0, 40, 12)
+	assert.Equal(t, 0, countColor(plain, corner, color.White))
+
+	options.Overlay = Overlay{Step: true, RMSD: true, Sparkline: true}
+	r, err = NewRenderer(options)
+	require.NoError(t, err)
+	pinCamera(t, r)
+	var images []image.Image
+	err = r.renderParallel(frames, func(_ Frame, img image.Image) error {
+		images = append(images, img)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, images, len(frames))
+	assert.Len(t, r.series, len(frames))
+	for i, img := range images {
+		assert.Equal(t, b, img.Bounds(), "frame %d", i)
+		assert.Greater(t, countColor(img, corner, color.White), 0, "frame %d", i)
+		chart := image.Rect(b.Max.X*2/3, b.Max.Y/2, b.Max.X, b.Max.Y)
+		assert.Greater(t, countColor(img, chart, markerColor), 0, "frame %d", i)
+	}
+	// The marker moves along the chart
+	assert.NotEqual(t, 0, countDifferent(images[0], images[len(images)-1]))
+}
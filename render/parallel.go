@@ -0,0 +1,202 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// triangleBytes is the memory held by each triangle of a mesh
+var triangleBytes = int64(unsafe.Sizeof(fauxgl.Triangle{}) + unsafe.Sizeof(&fauxgl.Triangle{}))
+
+// renderJob is a step on its way through the pipeline
+type renderJob struct {
+	seq  int
+	draw drawFunc
+	img  image.Image
+	err  error
+}
+
+// workerBytes estimates the buffers each worker keeps: the
+// color and depth buffers of its context and the intermediate
+// image of the downsampling
+func (r *Renderer) workerBytes() int64 {
+	size := r.contextSize()
+	pixels := int64(size.X) * int64(size.Y)
+	return pixels*(4+8) + pixels/int64(r.options.Scale)*4
+}
+
+// frameBytes estimates the memory a frame holds between
+// building its mesh and handing on its image
+func (r *Renderer) frameBytes(f *builtFrame) int64 {
+	size := r.contextSize()
+	pixels := int64(size.X) * int64(size.Y) / int64(r.options.Scale*r.options.Scale)
+	return int64(len(f.mesh.Triangles))*triangleBytes + pixels*4
+}
+
+// renderWorkers returns the number of workers that fit in the
+// memory limit. Each worker has up to two frames in flight: the
+// one it is drawing and one waiting to be drawn or handed on.
+func renderWorkers(workers int, limit, workerBytes, frameBytes int64) int {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if limit > 0 {
+		fit := int(limit / (workerBytes + 2*frameBytes))
+		if fit < 1 {
+			fit = 1
+		}
+		if fit < workers {
+			workers = fit
+		}
+	}
+	return workers
+}
+
+// buildFrame reads a frame and builds its mesh
+func (r *Renderer) buildFrame(frame Frame) (*builtFrame, error) {
+	model, err := ReadModel(frame.Path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := r.build(model, frame.Index)
+	if err != nil {
+		return nil, fmt.Errorf("frame %d: %v", frame.Index, err)
+	}
+	return f, nil
+}
+
+// renderParallel draws frames concurrently and passes each
+// image to emit in the order of frames
+func (r *Renderer) renderParallel(frames []Frame, emit func(Frame, image.Image) error) error {
+	if len(frames) == 0 {
+		return nil
+	}
+	r.total = len(frames)
+	if r.options.Overlay.Sparkline && r.series == nil {
+		series, err := trajectoryRMSD(frames, r.reference)
+		if err != nil {
+			return err
+		}
+		r.series = series
+	}
+	// The first frame positions the camera, which
+	// sets the size of the buffers
+	first, err := r.buildFrame(frames[0])
+	if err != nil {
+		return err
+	}
+	workers := renderWorkers(r.options.Workers, r.options.MemoryLimit, r.workerBytes(), r.frameBytes(first))
+	drawer := func(f *builtFrame) drawFunc {
+		return func(dc *fauxgl.Context) image.Image {
+			return r.draw(dc, f)
+		}
+	}
+	return runPipeline(len(frames), workers, r.newContext, drawer(first), func(i int) (drawFunc, error) {
+		f, err := r.buildFrame(frames[i])
+		if err != nil {
+			return nil, err
+		}
+		return drawer(f), nil
+	}, func(i int, img image.Image) error {
+		return emit(frames[i], img)
+	})
+}
+
+// drawFunc draws a step that has been built, using
+// a context that belongs to the calling worker
+type drawFunc func(dc *fauxgl.Context) image.Image
+
+// runPipeline draws n steps and passes each image to emit in
+// order. Steps are built one at a time in order, since building
+// carries state from one to the next, then drawn by a pool of
+// workers that each reuse a context. first is the step that was
+// built to size the pool. The steps in flight are bounded, so a
+// slow emit holds up rendering rather than piling up images.
+func runPipeline(
+	n int,
+	workers int,
+	newContext func() *fauxgl.Context,
+	first drawFunc,
+	build func(i int) (drawFunc, error),
+	emit func(i int, img image.Image) error,
+) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tokens := make(chan struct{}, 2*workers)
+	jobs := make(chan *renderJob, workers)
+	results := make(chan *renderJob, workers)
+	go func() {
+		defer close(jobs)
+		for i := 0; i < n; i++ {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			job := &renderJob{seq: i}
+			if i == 0 {
+				job.draw, first = first, nil
+			} else {
+				job.draw, job.err = build(i)
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+			if job.err != nil {
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			var dc *fauxgl.Context
+			for job := range jobs {
+				if job.err == nil {
+					if dc == nil {
+						dc = newContext()
+					}
+					job.img = job.draw(dc)
+					job.draw = nil
+				}
+				select {
+				case results <- job:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]*renderJob)
+	next := 0
+	for job := range results {
+		pending[job.seq] = job
+		for job := pending[next]; job != nil; job = pending[next] {
+			delete(pending, next)
+			next++
+			if job.err != nil {
+				return job.err
+			}
+			if err := emit(job.seq, job.img); err != nil {
+				return err
+			}
+			<-tokens
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderWorkers(t *testing.T) {
+	assert.Equal(t, runtime.NumCPU(), renderWorkers(0, 0, 100, 10))
+	assert.Equal(t, 8, renderWorkers(8, 0, 100, 10))
+	// Each worker needs 100 bytes and two frames of 10
+	assert.Equal(t, 4, renderWorkers(8, 480, 100, 10))
+	assert.Equal(t, 8, renderWorkers(8, 10000, 100, 10))
+	// At least one worker, however little memory there is
+	assert.Equal(t, 1, renderWorkers(8, 1, 100, 10))
+}
+
+func TestNewRendererInvalidWorkers(t *testing.T) {
+	options := DefaultOptions()
+	options.Workers = -1
+	_, err := NewRenderer(options)
+	require.Error(t, err)
+	options = DefaultOptions()
+	options.MemoryLimit = -1
+	_, err = NewRenderer(options)
+	require.Error(t, err)
+}
+
+// renderSequential draws frames one after another with Render,
+// from the viewpoint of camera. ribbon places the camera from a
+// random sample of the atoms, so it varies between renderers.
+func renderSequential(t *testing.T, frames []Frame, options Options, camera *Renderer) []image.Image {
+	r, err := NewRenderer(options)
+	require.NoError(t, err)
+	r.camera, r.matrix = camera.camera, camera.matrix
+	var images []image.Image
+	for _, frame := range frames {
+		model, err := ReadModel(frame.Path)
+		require.NoError(t, err)
+		img, err := r.Render(model)
+		require.NoError(t, err)
+		images = append(images, img)
+	}
+	return images
+}
+
+// repeatFrames returns the fixture frames n times over
+func repeatFrames(t testing.TB, n int) []Frame {
+	fixture, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	var frames []Frame
+	for i := 0; i < n; i++ {
+		for _, frame := range fixture {
+			frame.Index = len(frames)
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+func TestRenderParallel(t *testing.T) {
+	frames := repeatFrames(t, 3)
+	for _, scale := range []int{1, 2} {
+		options := DefaultOptions()
+		options.Size = 32
+		options.Scale = scale
+		options.Smoothing = 0.5
+		options.Workers = 4
+		r, err := NewRenderer(options)
+		require.NoError(t, err)
+		var images []image.Image
+		var indices []int
+		err = r.renderParallel(frames, func(frame Frame, img image.Image) error {
+			images = append(images, img)
+			indices = append(indices, frame.Index)
+			return nil
+		})
+		require.NoError(t, err)
+		expected := renderSequential(t, frames, options, r)
+		for i := range expected {
+			assert.Equal(t, 0, countDifferent(expected[i], images[i]), "frame %d", i)
+		}
+		require.Len(t, indices, len(frames))
+		for i, index := range indices {
+			assert.Equal(t, i, index)
+		}
+	}
+}
+
+func TestRenderParallelErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frames-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	frames := repeatFrames(t, 2)
+	broken := filepath.Join(dir, "2l0e_minim_4.pdb")
+	require.NoError(t, ioutil.WriteFile(broken, nil, 0644))
+	frames[4].Path = broken
+
+	options := DefaultOptions()
+	options.Size = 16
+	options.Scale = 1
+	options.Workers = 2
+	r, err := NewRenderer(options)
+	require.NoError(t, err)
+	emitted := 0
+	err = r.renderParallel(frames, func(Frame, image.Image) error {
+		emitted++
+		return nil
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), broken)
+	assert.Equal(t, 4, emitted)
+
+	r, err = NewRenderer(options)
+	require.NoError(t, err)
+	err = r.renderParallel(frames[:4], func(frame Frame, img image.Image) error {
+		if frame.Index == 1 {
+			return fmt.Errorf("full")
+		}
+		return nil
+	})
+	require.EqualError(t, err, "full")
+}
+
+func benchmarkRenderFrames(b *testing.B, workers int) {
+	frames := repeatFrames(b, 4)
+	options := DefaultOptions()
+	options.Size = 256
+	options.Scale = 2
+	options.Workers = workers
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := NewRenderer(options)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := r.renderParallel(frames, func(Frame, image.Image) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRenderFramesSequential(b *testing.B) {
+	benchmarkRenderFrames(b, 1)
+}
+
+func BenchmarkRenderFramesParallel(b *testing.B) {
+	benchmarkRenderFrames(b, 0)
+}
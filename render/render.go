@@ -0,0 +1,410 @@
+// Package render draws simulation frames as ribbon diagrams
+package render
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/ribbon/pdb"
+	"github.com/fogleman/ribbon/ribbon"
+	"github.com/nfnt/resize"
+	"github.com/thavlik/foldy-operator/proteinnet"
+)
+
+// Options control how frames are drawn
+type Options struct {
+	// Size is the height of each frame in pixels. The
+	// width follows the aspect ratio of the camera.
+	Size int
+
+	// Scale is the supersampling factor. Frames are drawn
+	// Scale times larger, then downsampled to Size.
+	Scale int
+
+	Background fauxgl.Color
+	Ambient    fauxgl.Color
+	Diffuse    fauxgl.Color
+
+	// Align superposes the alpha carbons of every frame onto
+	// the first, so the protein doesn't drift or tumble as it
+	// diffuses through the water box
+	Align bool
+
+	// Smoothing blends each frame with the ones before it to
+	// hide thermal jitter. It is the weight given to the past,
+	// from 0 (no smoothing) up to but not including 1.
+	Smoothing float64
+
+	// Mask is the ProteinNet mask of the chain. When set, the
+	// residues next to missing ones are drawn in GapColor and
+	// internal gaps are bridged with dashed connectors.
+	Mask     string
+	GapColor fauxgl.Color
+
+	// Legend explains the gap highlighting in the
+	// bottom left corner of each frame
+	Legend bool
+
+	// Color is what the ribbon is colored by, ColorChain if empty
+	Color ColorScheme
+
+	// Gradient maps values onto colors. If nil, each
+	// scheme has a gradient of its own.
+	Gradient *ribbon.Colormap
+
+	// ColorMin and ColorMax are the values at either end of
+	// the gradient for ColorRMSD and ColorBFactor. If both are
+	// zero, RMSD spans 0 to 3 Å and B-factors span the range of
+	// the first frame.
+	ColorMin float64
+	ColorMax float64
+
+	// Reference is the structure that frames are aligned to and
+	// that ColorRMSD measures against, instead of the first frame.
+	// It must have the same residues as the frames.
+	Reference *pdb.Model
+
+	// Secondary is the DSSP secondary structure of each residue
+	// in the structure, for ColorSecondary. Without it, the HELIX
+	// and SHEET records of the frames are used.
+	Secondary string
+
+	// Workers is the number of frames RenderFrames and
+	// RenderVideo draw at once, runtime.NumCPU() if zero
+	Workers int
+
+	// MemoryLimit is roughly the most memory in bytes that the
+	// frames being drawn may hold, including the supersampled
+	// buffers of each worker. Fewer workers are used if they
+	// wouldn't fit, but always at least one. Zero is no limit.
+	MemoryLimit int64
+
+	// Overlay is the text and chart drawn over each frame
+	Overlay Overlay
+
+	// Camera is how the camera moves over the video. The
+	// zero value keeps the view of the first frame.
+	Camera CameraPath
+}
+
+// DefaultOptions are the settings used for the published videos
+func DefaultOptions() Options {
+	return Options{
+		Size:       2048,
+		Scale:      4,
+		Background: fauxgl.HexColor("1D181F"),
+		Ambient:    fauxgl.Gray(0.3),
+		Diffuse:    fauxgl.Gray(0.9),
+		Align:      true,
+		GapColor:   fauxgl.HexColor("FF4F3F"),
+	}
+}
+
+// ParseColor parses a color in RRGGBB hex notation,
+// with or without a leading #
+func ParseColor(s string) (fauxgl.Color, error) {
+	hex := s
+	if len(hex) > 0 && hex[0] == '#' {
+		hex = hex[1:]
+	}
+	if len(hex) != 6 {
+		return fauxgl.Color{}, fmt.Errorf("invalid color '%s', expected RRGGBB", s)
+	}
+	for _, c := range hex {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return fauxgl.Color{}, fmt.Errorf("invalid color '%s', expected RRGGBB", s)
+		}
+	}
+	return fauxgl.HexColor(hex), nil
+}
+
+// Renderer draws the frames of a trajectory. The camera and
+// the transform that fits the mesh in the view are found for
+// the first frame and kept for the rest, so that the structure
+// moves rather than the viewpoint, unless Options.Camera moves
+// the camera along a path from there.
+type Renderer struct {
+	options   Options
+	camera    *ribbon.Camera
+	matrix    fauxgl.Matrix
+	reference []fauxgl.Vector
+	rmsd      float64
+	smoothed  []fauxgl.Vector
+	gaps      *gapHighlight
+	colorer   *colorer
+	rig       *cameraRig
+	// total is the number of frames in the video, if known,
+	// which the camera path is spread over
+	total int
+	// context is reused by Render, since allocating
+	// one per frame dominates its memory use
+	context *fauxgl.Context
+	// built is the number of frames built so far
+	built int
+	// history holds the RMSD of each frame built, and series
+	// that of the whole trajectory if it was read in advance
+	history []float64
+	series  []float64
+}
+
+// builtFrame is a frame ready to be drawn
+type builtFrame struct {
+	mesh   *fauxgl.Mesh
+	camera ribbon.Camera
+	step   int
+	rmsd   float64
+	// rmsds is charted on the sparkline,
+	// with this frame at position
+	rmsds    []float64
+	position int
+}
+
+// NewRenderer returns a Renderer that draws with options
+func NewRenderer(options Options) (*Renderer, error) {
+	if options.Size <= 0 {
+		return nil, fmt.Errorf("expected positive size, got %d", options.Size)
+	}
+	if options.Scale <= 0 {
+		return nil, fmt.Errorf("expected positive scale, got %d", options.Scale)
+	}
+	if options.Smoothing < 0 || options.Smoothing >= 1 {
+		return nil, fmt.Errorf("expected smoothing in [0, 1), got %v", options.Smoothing)
+	}
+	if options.Workers < 0 {
+		return nil, fmt.Errorf("expected non-negative workers, got %d", options.Workers)
+	}
+	if options.MemoryLimit < 0 {
+		return nil, fmt.Errorf("expected non-negative memory limit, got %d", options.MemoryLimit)
+	}
+	colorer, err := newColorer(options)
+	if err != nil {
+		return nil, err
+	}
+	rig, err := newCameraRig(options.Camera)
+	if err != nil {
+		return nil, err
+	}
+	r := &Renderer{
+		options: options,
+		colorer: colorer,
+		rig:     rig,
+	}
+	if options.Reference != nil && (options.Align || options.Overlay.needsRMSD()) {
+		r.reference = CAPositions(options.Reference)
+	}
+	if options.Mask != "" {
+		if err := validateMask(options.Mask); err != nil {
+			return nil, fmt.Errorf("mask: %v", err)
+		}
+		r.gaps = newGapHighlight(options.Mask)
+	}
+	return r, nil
+}
+
+// prepare superposes and smooths model in place. The RMSD
+// is measured even without Align if the overlay shows it.
+func (r *Renderer) prepare(model *pdb.Model) error {
+	if r.options.Align || r.options.Overlay.needsRMSD() {
+		if r.reference == nil {
+			r.reference = CAPositions(model)
+			if len(r.reference) == 0 {
+				return fmt.Errorf("no alpha carbons to align")
+			}
+		} else if r.options.Align {
+			rmsd, err := Superpose(model, r.reference)
+			if err != nil {
+				return fmt.Errorf("align: %v", err)
+			}
+			r.rmsd = rmsd
+		} else {
+			_, rmsd, err := superpose(CAPositions(model), r.reference)
+			if err != nil {
+				return fmt.Errorf("rmsd: %v", err)
+			}
+			r.rmsd = rmsd
+		}
+	}
+	if r.options.Smoothing > 0 {
+		r.smooth(model)
+	}
+	return nil
+}
+
+// smooth moves each atom towards its previous smoothed
+// position. Smoothing restarts if the atoms change.
+func (r *Renderer) smooth(model *pdb.Model) {
+	atoms := append(append([]*pdb.Atom{}, model.Atoms...), model.HetAtoms...)
+	if len(atoms) != len(r.smoothed) {
+		r.smoothed = make([]fauxgl.Vector, len(atoms))
+		for i, a := range atoms {
+			r.smoothed[i] = atomPosition(a)
+		}
+		return
+	}
+	w := r.options.Smoothing
+	for i, a := range atoms {
+		p := r.smoothed[i].MulScalar(w).Add(atomPosition(a).MulScalar(1 - w))
+		r.smoothed[i] = p
+		a.X, a.Y, a.Z = p.X, p.Y, p.Z
+	}
+}
+
+// RMSD returns the CA RMSD of the last frame from the first,
+// after superposition. It is zero unless Align is set or the
+// overlay shows the RMSD.
+func (r *Renderer) RMSD() float64 {
+	return r.rmsd
+}
+
+// Render draws a single frame. With Align or Smoothing
+// set, the atoms of model are moved.
+func (r *Renderer) Render(model *pdb.Model) (image.Image, error) {
+	f, err := r.build(model, r.built)
+	if err != nil {
+		return nil, err
+	}
+	if r.context == nil {
+		r.context = r.newContext()
+	}
+	return r.draw(r.context, f), nil
+}
+
+// build returns the mesh of a frame, transformed to fit the
+// view. Frames must be built in order, since alignment,
+// smoothing and coloring depend on the frames before.
+func (r *Renderer) build(model *pdb.Model, step int) (*builtFrame, error) {
+	mesh, err := r.buildMesh(model)
+	if err != nil {
+		return nil, err
+	}
+	r.fit(model, mesh)
+	return r.record(mesh, step), nil
+}
+
+// buildMesh prepares model and returns its colored
+// mesh, before it is fit to the view
+func (r *Renderer) buildMesh(model *pdb.Model) (*fauxgl.Mesh, error) {
+	if err := r.prepare(model); err != nil {
+		return nil, err
+	}
+	// The same as ribbon.ModelMesh, colored before
+	// the het atoms are added
+	mesh := ribbon.RibbonMesh(model)
+	cas := CAPositions(model)
+	var residues []int
+	if r.colorer.needsResidues() || r.gaps != nil {
+		residues = triangleResidues(mesh.Triangles, cas)
+	}
+	if err := r.colorer.apply(mesh, model, residues); err != nil {
+		return nil, fmt.Errorf("color: %v", err)
+	}
+	if r.gaps != nil {
+		if known := proteinnet.KnownCount(r.options.Mask); known != len(cas) {
+			return nil, fmt.Errorf("mask has %d known residues, but the model has %d", known, len(cas))
+		}
+		r.gaps.apply(mesh, cas, residues, r.options.GapColor)
+	}
+	mesh.Add(ribbon.HetMesh(model))
+	return mesh, nil
+}
+
+// fit transforms mesh into the view. The first
+// mesh fit positions the camera.
+func (r *Renderer) fit(model *pdb.Model, mesh *fauxgl.Mesh) {
+	if r.camera == nil {
+		r.matrix = mesh.BiUnitCube()
+		camera := ribbon.PositionCamera(model, r.matrix)
+		r.camera = &camera
+	} else {
+		mesh.Transform(r.matrix)
+	}
+}
+
+// record returns the frame of mesh, which has been fit, places
+// the camera for it and counts it towards the sparkline
+func (r *Renderer) record(mesh *fauxgl.Mesh, step int) *builtFrame {
+	f := &builtFrame{
+		mesh:     mesh,
+		camera:   r.rig.at(*r.camera, mesh, r.built, r.total),
+		step:     step,
+		rmsd:     r.rmsd,
+		rmsds:    r.series,
+		position: r.built,
+	}
+	r.history = append(r.history, r.rmsd)
+	if f.rmsds == nil {
+		f.rmsds = r.history
+	}
+	r.built++
+	return f
+}
+
+// contextSize returns the size of the supersampled image.
+// The camera is positioned by the first frame, and the
+// path it takes from there keeps its aspect ratio.
+func (r *Renderer) contextSize() image.Point {
+	height := r.options.Size * r.options.Scale
+	return image.Pt(int(float64(height)*r.camera.Aspect), height)
+}
+
+func (r *Renderer) newContext() *fauxgl.Context {
+	size := r.contextSize()
+	return fauxgl.NewContext(size.X, size.Y)
+}
+
+// draw shades mesh into context and downsamples it. It
+// only reads the renderer, so frames that have been built
+// can be drawn concurrently, each with its own context.
+func (r *Renderer) draw(context *fauxgl.Context, f *builtFrame) image.Image {
+	camera := f.camera
+	size := r.options.Size
+	matrix := fauxgl.LookAt(camera.Eye, camera.Center, camera.Up).Perspective(camera.Fovy, camera.Aspect, 1, 100)
+	light := camera.Eye.Sub(camera.Center).Normalize()
+	shader := fauxgl.NewPhongShader(matrix, light, camera.Eye)
+	shader.AmbientColor = r.options.Ambient
+	shader.DiffuseColor = r.options.Diffuse
+	context.Shader = shader
+	context.ClearColorBufferWith(r.options.Background)
+	context.ClearDepthBuffer()
+	context.DrawTriangles(f.mesh.Triangles)
+	img := resize.Resize(uint(float64(size)*camera.Aspect), uint(size), context.Image(), resize.Bilinear)
+	if img == context.Image() {
+		// Without supersampling, resize returns the buffer
+		// itself, which the next frame would draw over
+		img = toRGBA(img)
+	}
+	legend := r.options.Legend && r.gaps != nil
+	overlay := r.options.Overlay
+	if !legend && !overlay.enabled() {
+		return img
+	}
+	frame := toRGBA(img)
+	fg := textColor(r.options.Background)
+	if legend {
+		drawLegend(frame, r.gaps.legend(), r.options.GapColor.NRGBA(), fg)
+	}
+	drawLines(frame, overlay.lines(f.step, f.rmsd), fg)
+	if overlay.Sparkline {
+		drawSparkline(frame, f.rmsds, f.position, fg, markerColor)
+	}
+	return frame
+}
+
+// ReadModel reads the first model of a PDB file
+func ReadModel(path string) (*pdb.Model, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	models, err := pdb.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("%s: no models", path)
+	}
+	return models[0], nil
+}
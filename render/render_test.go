@@ -0,0 +1,61 @@
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#1D181F")
+	require.NoError(t, err)
+	assert.Equal(t, fauxgl.HexColor("1D181F"), c)
+	c, err = ParseColor("ffffff")
+	require.NoError(t, err)
+	assert.Equal(t, fauxgl.White, c)
+	for _, s := range []string{"", "#fff", "1D181G", "#1D181F0"} {
+		_, err := ParseColor(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestNewRendererInvalid(t *testing.T) {
+	options := DefaultOptions()
+	options.Size = 0
+	_, err := NewRenderer(options)
+	require.Error(t, err)
+	options = DefaultOptions()
+	options.Scale = -1
+	_, err = NewRenderer(options)
+	require.Error(t, err)
+}
+
+func TestRender(t *testing.T) {
+	frames, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	model, err := ReadModel(frames[0].Path)
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.Size = 64
+	options.Scale = 2
+	r, err := NewRenderer(options)
+	require.NoError(t, err)
+	img, err := r.Render(model)
+	require.NoError(t, err)
+	bounds := img.Bounds()
+	require.Equal(t, 64, bounds.Dy())
+	require.Greater(t, bounds.Dx(), 0)
+	background := color.NRGBAModel.Convert(options.Background.NRGBA())
+	drawn := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if color.NRGBAModel.Convert(img.At(x, y)) != background {
+				drawn++
+			}
+		}
+	}
+	assert.Greater(t, drawn, 0, "expected the ribbon to be drawn")
+}
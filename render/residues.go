@@ -0,0 +1,80 @@
+package render
+
+import (
+	"math"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// residueCell is the edge of the grid cells used to find the
+// nearest alpha carbon, in Ångströms. The ribbon never strays
+// this far from the backbone, so the search rarely widens.
+const residueCell = 8.0
+
+// residueLocator finds the residue nearest a point
+type residueLocator struct {
+	points []fauxgl.Vector
+	grid   map[[3]int][]int
+}
+
+func newResidueLocator(points []fauxgl.Vector) *residueLocator {
+	l := &residueLocator{
+		points: points,
+		grid:   make(map[[3]int][]int),
+	}
+	for i, p := range points {
+		key := cellOf(p)
+		l.grid[key] = append(l.grid[key], i)
+	}
+	return l
+}
+
+func cellOf(p fauxgl.Vector) [3]int {
+	return [3]int{
+		int(math.Floor(p.X / residueCell)),
+		int(math.Floor(p.Y / residueCell)),
+		int(math.Floor(p.Z / residueCell)),
+	}
+}
+
+// nearest returns the index of the point closest to p,
+// or -1 if there are no points
+func (l *residueLocator) nearest(p fauxgl.Vector) int {
+	best, bestDist := -1, math.Inf(1)
+	c := cellOf(p)
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			for dz := -1; dz <= 1; dz++ {
+				for _, i := range l.grid[[3]int{c[0] + dx, c[1] + dy, c[2] + dz}] {
+					if d := l.points[i].Sub(p).LengthSquared(); d < bestDist {
+						best, bestDist = i, d
+					}
+				}
+			}
+		}
+	}
+	if bestDist <= residueCell*residueCell {
+		// Anything outside the neighboring cells is further away
+		return best
+	}
+	for i, q := range l.points {
+		if d := q.Sub(p).LengthSquared(); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// triangleResidues assigns each triangle to the residue whose
+// alpha carbon is nearest its centroid. The ribbon mesh doesn't
+// record which residue a triangle was made for, and the segments
+// it builds between peptide planes don't line up with residues.
+func triangleResidues(triangles []*fauxgl.Triangle, cas []fauxgl.Vector) []int {
+	l := newResidueLocator(cas)
+	residues := make([]int, len(triangles))
+	for i, t := range triangles {
+		centroid := t.V1.Position.Add(t.V2.Position).Add(t.V3.Position).DivScalar(3)
+		residues[i] = l.nearest(centroid)
+	}
+	return residues
+}
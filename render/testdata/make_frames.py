@@ -0,0 +1,65 @@
+"""Generates a synthetic trajectory of the known residues of 2L0E.
+
+The backbone is an ideal alpha helix that bends a little more with
+each frame, followed by a few waters like GROMACS output:
+
+    python3 make_frames.py 2l0e_minim 3
+"""
+import math
+import os
+import sys
+
+SEQUENCE = 'KKKDNLLFGSIISAVDPVAVLAVFEEIHKKK'
+THREE = {
+    'A': 'ALA', 'D': 'ASP', 'E': 'GLU', 'F': 'PHE', 'G': 'GLY', 'H': 'HIS',
+    'I': 'ILE', 'K': 'LYS', 'L': 'LEU', 'N': 'ASN', 'P': 'PRO', 'S': 'SER',
+    'V': 'VAL',
+}
+# radius (A), angle offset (degrees) and rise (A) of each backbone atom
+BACKBONE = [
+    ('N', 1.55, -28.0, -0.9),
+    ('CA', 2.30, 0.0, 0.0),
+    ('C', 1.60, 28.0, 0.9),
+    ('O', 1.90, 30.0, 2.1),
+]
+
+
+def atom_line(serial, name, res_name, res_seq, x, y, z):
+    element = name[0]
+    name = (' ' + name).ljust(4) if len(name) < 4 else name
+    return 'ATOM  {:5d} {:4s} {:3s} A{:4d}    {:8.3f}{:8.3f}{:8.3f}{:6.2f}{:6.2f}          {:>2s}  '.format(
+        serial, name, res_name, res_seq, x, y, z, 1.0, 0.0, element)
+
+
+def frame(step):
+    lines = ['MODEL        1']
+    serial = 1
+    bend = 0.004 * step
+    for i, aa in enumerate(SEQUENCE):
+        for name, r, offset, rise in BACKBONE:
+            theta = math.radians(i * 100.0 + offset)
+            z = i * 1.5 + rise
+            x = r * math.cos(theta) + bend * z * z
+            y = r * math.sin(theta)
+            lines.append(atom_line(serial, name, THREE[aa], i + 1, x, y, z))
+            serial += 1
+    for i in range(2):
+        res_seq = len(SEQUENCE) + i + 1
+        for j, name in enumerate(['OW', 'HW1', 'HW2']):
+            lines.append(atom_line(serial, name, 'SOL', res_seq, 10.0 + i * 3, j * 0.6, 5.0))
+            serial += 1
+    lines += ['TER', 'ENDMDL', '']
+    return '\n'.join(lines)
+
+
+def main(out_dir, steps):
+    os.makedirs(out_dir, exist_ok=True)
+    prefix = os.path.basename(out_dir.rstrip('/'))
+    for step in range(steps):
+        path = os.path.join(out_dir, '{}_{}.pdb'.format(prefix, step))
+        with open(path, 'w') as f:
+            f.write(frame(step))
+
+
+if __name__ == '__main__':
+    main(sys.argv[1], int(sys.argv[2]))
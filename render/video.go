@@ -0,0 +1,164 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format is the container of an encoded video
+type Format string
+
+// Formats encoded with ffmpeg, which must be on the PATH
+const (
+	MP4  Format = "mp4"
+	WebM Format = "webm"
+)
+
+// GIF is encoded natively
+const GIF Format = "gif"
+
+// ParseFormat returns the format with the given name
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case MP4, WebM, GIF:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown video format '%s', expected mp4, webm or gif", s)
+	}
+}
+
+// FormatFromPath returns the format named by the extension of path
+func FormatFromPath(path string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// VideoOptions control how frames are encoded
+type VideoOptions struct {
+	Format Format
+
+	// FrameRate is the number of frames per second
+	FrameRate int
+
+	// Quality ranges from 1 (smallest file) to 100 (best
+	// looking). For MP4 and WebM it maps linearly onto the
+	// constant rate factor of the codec. GIFs are dithered
+	// when it is at least 50.
+	Quality int
+}
+
+// DefaultVideoOptions are the frame rate of create-video and
+// a quality a little above the defaults of the codecs
+func DefaultVideoOptions() VideoOptions {
+	return VideoOptions{
+		Format:    MP4,
+		FrameRate: 30,
+		Quality:   60,
+	}
+}
+
+func (o VideoOptions) validate() error {
+	if o.FrameRate <= 0 {
+		return fmt.Errorf("expected positive frame rate, got %d", o.FrameRate)
+	}
+	if o.Quality < 1 || o.Quality > 100 {
+		return fmt.Errorf("expected quality between 1 and 100, got %d", o.Quality)
+	}
+	return nil
+}
+
+// Encoder writes frames to a video as they are rendered.
+// Every frame must be the same size as the first.
+type Encoder interface {
+	Encode(img image.Image) error
+
+	// Close finishes the video. It must be called even
+	// if Encode fails, to release the output.
+	Close() error
+}
+
+// CreateVideo returns an Encoder writing to path
+func CreateVideo(path string, options VideoOptions) (Encoder, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	switch options.Format {
+	case MP4, WebM:
+		return newFFmpegEncoder(path, options), nil
+	case GIF:
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		return &fileEncoder{
+			Encoder: newGIFEncoder(f, options),
+			f:       f,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown video format '%s'", options.Format)
+	}
+}
+
+// fileEncoder closes the file an Encoder writes to
+type fileEncoder struct {
+	Encoder
+	f *os.File
+}
+
+func (e *fileEncoder) Close() error {
+	err := e.Encoder.Close()
+	if cerr := e.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// toRGBA returns img as an *image.RGBA with its origin at 0,0
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+// checkSize returns an error if img does not match the first frame
+func checkSize(first *image.Point, img image.Image) error {
+	size := img.Bounds().Size()
+	if *first == (image.Point{}) {
+		*first = size
+		return nil
+	}
+	if size != *first {
+		return fmt.Errorf("frame is %dx%d, expected %dx%d", size.X, size.Y, first.X, first.Y)
+	}
+	return nil
+}
+
+// RenderVideo draws each frame straight into a video at path
+func RenderVideo(frames []Frame, path string, options Options, video VideoOptions) (err error) {
+	r, err := NewRenderer(options)
+	if err != nil {
+		return err
+	}
+	e, err := CreateVideo(path, video)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return r.renderParallel(frames, func(frame Frame, img image.Image) error {
+		if err := e.Encode(img); err != nil {
+			return fmt.Errorf("frame %d: %v", frame.Index, err)
+		}
+		return nil
+	})
+}
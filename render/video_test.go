@@ -0,0 +1,148 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	for path, expected := range map[string]Format{
+		"2l0e.mp4":      MP4,
+		"out/2l0e.WebM": WebM,
+		"/tmp/2l0e.gif": GIF,
+	} {
+		format, err := FormatFromPath(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, expected, format, path)
+	}
+	_, err := FormatFromPath("2l0e.avi")
+	require.Error(t, err)
+	_, err = FormatFromPath("2l0e")
+	require.Error(t, err)
+}
+
+func TestVideoOptionsInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "video-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	options := DefaultVideoOptions()
+	options.FrameRate = 0
+	_, err = CreateVideo(filepath.Join(dir, "a.mp4"), options)
+	require.Error(t, err)
+	options = DefaultVideoOptions()
+	options.Quality = 101
+	_, err = CreateVideo(filepath.Join(dir, "a.mp4"), options)
+	require.Error(t, err)
+}
+
+func TestFFmpegArgs(t *testing.T) {
+	options := DefaultVideoOptions()
+	args := ffmpegArgs("out.mp4", image.Pt(641, 360), options)
+	assert.Contains(t, args, "641x360")
+	assert.Contains(t, args, "libx264")
+	assert.Equal(t, "out.mp4", args[len(args)-1])
+	options.Format = WebM
+	options.FrameRate = 24
+	args = ffmpegArgs("out.webm", image.Pt(640, 360), options)
+	assert.Contains(t, args, "libvpx-vp9")
+	assert.Contains(t, args, "24")
+}
+
+func TestCRF(t *testing.T) {
+	assert.Equal(t, 0, crf(MP4, 100))
+	assert.Equal(t, 50, crf(MP4, 1))
+	assert.Equal(t, 20, crf(MP4, 60))
+	assert.Equal(t, 25, crf(WebM, 60))
+}
+
+// solid returns a frame filled with c
+func solid(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b, a := c.RGBA()
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = byte(r>>8), byte(g>>8), byte(b>>8), byte(a>>8)
+	}
+	return img
+}
+
+func TestGIFEncoder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "video-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.gif")
+	options := DefaultVideoOptions()
+	options.Format = GIF
+	options.FrameRate = 25
+	e, err := CreateVideo(path, options)
+	require.NoError(t, err)
+	colors := []color.Color{color.Black, color.White, color.RGBA{R: 255, A: 255}}
+	for _, c := range colors {
+		// Wide enough that the image data spans many sub-blocks
+		require.NoError(t, e.Encode(solid(300, 20, c)))
+	}
+	require.Error(t, e.Encode(solid(20, 300, color.Black)))
+	require.NoError(t, e.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	require.NoError(t, err)
+	require.Len(t, g.Image, len(colors))
+	assert.Equal(t, 0, g.LoopCount)
+	for i, c := range colors {
+		assert.Equal(t, 4, g.Delay[i])
+		assert.Equal(t, image.Rect(0, 0, 300, 20), g.Image[i].Bounds())
+		r0, g0, b0, _ := c.RGBA()
+		r1, g1, b1, _ := g.Image[i].At(150, 10).RGBA()
+		assert.Equal(t, []uint32{r0, g0, b0}, []uint32{r1, g1, b1}, "frame %d", i)
+	}
+}
+
+func TestGIFEncoderEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "video-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	options := DefaultVideoOptions()
+	options.Format = GIF
+	e, err := CreateVideo(filepath.Join(dir, "out.gif"), options)
+	require.NoError(t, err)
+	require.Error(t, e.Close())
+}
+
+func TestRenderVideo(t *testing.T) {
+	frames, err := FindFrames("testdata/2l0e_minim")
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "video-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	options := DefaultOptions()
+	options.Size = 32
+	options.Scale = 1
+	formats := []Format{GIF}
+	if _, err := exec.LookPath("ffmpeg"); err == nil {
+		formats = append(formats, MP4, WebM)
+	} else {
+		t.Log("ffmpeg not found, only testing GIF")
+	}
+	for _, format := range formats {
+		t.Run(string(format), func(t *testing.T) {
+			video := DefaultVideoOptions()
+			video.Format = format
+			path := filepath.Join(dir, "2l0e."+string(format))
+			require.NoError(t, RenderVideo(frames, path, options, video))
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Greater(t, info.Size(), int64(0))
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// SchedulingHints are per-request scheduling preferences. A
+// request may name a profile from the operator's scheduling
+// config, and may add node selectors, tolerations and a priority
+// class as long as the config allows them.
+type SchedulingHints struct {
+	Profile           string            `json:"profile,omitempty"`
+	NodeSelector      map[string]string `json:"node_selector,omitempty"`
+	Tolerations       []v1.Toleration   `json:"tolerations,omitempty"`
+	PriorityClassName string            `json:"priority_class_name,omitempty"`
+}
+
+// SchedulingProfile is a set of pod scheduling constraints
+type SchedulingProfile struct {
+	NodeSelector      map[string]string `json:"node_selector,omitempty"`
+	Tolerations       []v1.Toleration   `json:"tolerations,omitempty"`
+	Affinity          *v1.Affinity      `json:"affinity,omitempty"`
+	PriorityClassName string            `json:"priority_class_name,omitempty"`
+}
+
+// StepProfile selects a profile for runs of at least MinSteps
+// steps, when the request does not name one.
+type StepProfile struct {
+	MinSteps int    `json:"min_steps"`
+	Profile  string `json:"profile"`
+}
+
+// SchedulingAllowlist limits what requests may ask for
+type SchedulingAllowlist struct {
+	NodeSelectorKeys []string `json:"node_selector_keys,omitempty"`
+	TolerationKeys   []string `json:"toleration_keys,omitempty"`
+	PriorityClasses  []string `json:"priority_classes,omitempty"`
+}
+
+// SchedulingConfig is the operator-level scheduling configuration
+type SchedulingConfig struct {
+	Default      SchedulingProfile            `json:"default"`
+	Profiles     map[string]SchedulingProfile `json:"profiles,omitempty"`
+	StepProfiles []StepProfile                `json:"step_profiles,omitempty"`
+	Allowed      SchedulingAllowlist          `json:"allowed"`
+}
+
+func loadSchedulingConfig(path string) (*SchedulingConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &SchedulingConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("unmarshal: %v", err)
+	}
+	for _, sp := range config.StepProfiles {
+		if _, ok := config.Profiles[sp.Profile]; !ok {
+			return nil, fmt.Errorf("step profile references unknown profile '%s'", sp.Profile)
+		}
+	}
+	// Highest threshold first, so the first match wins
+	sort.Slice(config.StepProfiles, func(i, j int) bool {
+		return config.StepProfiles[i].MinSteps > config.StepProfiles[j].MinSteps
+	})
+	return config, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// validate returns an error if hints asks for anything
+// not permitted by the allowlist.
+func (c *SchedulingConfig) validate(hints *SchedulingHints) error {
+	if hints.Profile != "" {
+		if _, ok := c.Profiles[hints.Profile]; !ok {
+			return fmt.Errorf("unknown scheduling profile '%s'", hints.Profile)
+		}
+	}
+	for k := range hints.NodeSelector {
+		if !contains(c.Allowed.NodeSelectorKeys, k) {
+			return fmt.Errorf("node selector key '%s' not allowed", k)
+		}
+	}
+	for _, t := range hints.Tolerations {
+		if !contains(c.Allowed.TolerationKeys, t.Key) {
+			return fmt.Errorf("toleration key '%s' not allowed", t.Key)
+		}
+	}
+	if hints.PriorityClassName != "" && !contains(c.Allowed.PriorityClasses, hints.PriorityClassName) {
+		return fmt.Errorf("priority class '%s' not allowed", hints.PriorityClassName)
+	}
+	return nil
+}
+
+func (p *SchedulingProfile) merge(other *SchedulingProfile) {
+	if len(other.NodeSelector) > 0 {
+		merged := make(map[string]string, len(p.NodeSelector)+len(other.NodeSelector))
+		for k, v := range p.NodeSelector {
+			merged[k] = v
+		}
+		for k, v := range other.NodeSelector {
+			merged[k] = v
+		}
+		p.NodeSelector = merged
+	}
+	if len(other.Tolerations) > 0 {
+		p.Tolerations = append(append([]v1.Toleration{}, p.Tolerations...), other.Tolerations...)
+	}
+	if other.Affinity != nil {
+		p.Affinity = other.Affinity
+	}
+	if other.PriorityClassName != "" {
+		p.PriorityClassName = other.PriorityClassName
+	}
+}
+
+// resolve combines the default profile, the profile named by the
+// request (or selected by its step count), and the request's own
+// hints, in that order of increasing precedence.
+func (c *SchedulingConfig) resolve(config *RunConfig) (*SchedulingProfile, error) {
+	resolved := &SchedulingProfile{}
+	resolved.merge(&c.Default)
+	hints := config.Scheduling
+	if hints == nil {
+		hints = &SchedulingHints{}
+	}
+	if err := c.validate(hints); err != nil {
+		return nil, err
+	}
+	profile := hints.Profile
+	if profile == "" {
+		for _, sp := range c.StepProfiles {
+			if config.Steps >= sp.MinSteps {
+				profile = sp.Profile
+				break
+			}
+		}
+	}
+	if profile != "" {
+		p := c.Profiles[profile]
+		resolved.merge(&p)
+	}
+	resolved.merge(&SchedulingProfile{
+		NodeSelector:      hints.NodeSelector,
+		Tolerations:       hints.Tolerations,
+		PriorityClassName: hints.PriorityClassName,
+	})
+	return resolved, nil
+}
+
+// applyScheduling sets the scheduling constraints for
+// config on the pod spec.
+func (s *server) applyScheduling(spec *v1.PodSpec, config *RunConfig) error {
+	profile, err := s.scheduling.resolve(config)
+	if err != nil {
+		return err
+	}
+	spec.NodeSelector = profile.NodeSelector
+	spec.Tolerations = profile.Tolerations
+	spec.Affinity = profile.Affinity
+	spec.PriorityClassName = profile.PriorityClassName
+	return nil
+}
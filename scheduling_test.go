@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+const testSchedulingConfig = `
+default:
+  tolerations:
+  - key: dedicated
+    operator: Equal
+    value: compute
+    effect: NoSchedule
+  node_selector:
+    pool: compute
+profiles:
+  spot:
+    node_selector:
+      lifecycle: spot
+    priority_class_name: preemptible
+  on-demand:
+    node_selector:
+      lifecycle: on-demand
+step_profiles:
+- min_steps: 0
+  profile: on-demand
+- min_steps: 1000
+  profile: spot
+allowed:
+  node_selector_keys: ["zone"]
+  toleration_keys: ["gpu"]
+  priority_classes: ["preemptible", "high"]
+`
+
+func loadTestSchedulingConfig(t *testing.T) *SchedulingConfig {
+	dir, err := ioutil.TempDir("", "scheduling")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "scheduling.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(testSchedulingConfig), 0644))
+	config, err := loadSchedulingConfig(path)
+	require.NoError(t, err)
+	return config
+}
+
+func TestSchedulingStepProfiles(t *testing.T) {
+	config := loadTestSchedulingConfig(t)
+	short, err := config.resolve(&RunConfig{Steps: 100})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"pool":      "compute",
+		"lifecycle": "on-demand",
+	}, short.NodeSelector)
+	assert.Equal(t, "", short.PriorityClassName)
+	assert.Len(t, short.Tolerations, 1)
+
+	long, err := config.resolve(&RunConfig{Steps: 5000})
+	require.NoError(t, err)
+	assert.Equal(t, "spot", long.NodeSelector["lifecycle"])
+	assert.Equal(t, "preemptible", long.PriorityClassName)
+}
+
+func TestSchedulingHints(t *testing.T) {
+	config := loadTestSchedulingConfig(t)
+	profile, err := config.resolve(&RunConfig{
+		Steps: 5000,
+		Scheduling: &SchedulingHints{
+			Profile:           "on-demand",
+			NodeSelector:      map[string]string{"zone": "sfo2"},
+			Tolerations:       []v1.Toleration{{Key: "gpu", Operator: v1.TolerationOpExists}},
+			PriorityClassName: "high",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "on-demand", profile.NodeSelector["lifecycle"])
+	assert.Equal(t, "sfo2", profile.NodeSelector["zone"])
+	assert.Equal(t, "high", profile.PriorityClassName)
+	assert.Len(t, profile.Tolerations, 2)
+
+	// The config's own profiles must not be modified
+	assert.Len(t, config.Default.Tolerations, 1)
+	assert.NotContains(t, config.Profiles["on-demand"].NodeSelector, "zone")
+}
+
+func TestSchedulingAllowlist(t *testing.T) {
+	config := loadTestSchedulingConfig(t)
+	for name, hints := range map[string]*SchedulingHints{
+		"profile":        {Profile: "gpu"},
+		"node selector":  {NodeSelector: map[string]string{"pool": "other"}},
+		"toleration":     {Tolerations: []v1.Toleration{{Key: "dedicated"}}},
+		"priority class": {PriorityClassName: "system-cluster-critical"},
+	} {
+		_, err := config.resolve(&RunConfig{Steps: 10, Scheduling: hints})
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testServer is a server backed by miniredis and fake
+// kubernetes clients, with timeouts short enough for tests
+type testServer struct {
+	*server
+	mr      *miniredis.Miniredis
+	kube    *fake.Clientset
+	dynamic *dynamicfake.FakeDynamicClient
+}
+
+func newTestServer(t *testing.T) *testServer {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	clientset := fake.NewSimpleClientset()
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	// The fake doesn't generate names or UIDs like the API server
+	created := 0
+	dynamicClient.PrependReactor("create", "simulations", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		u := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		created++
+		if u.GetName() == "" {
+			u.SetName(fmt.Sprintf("%s%05d", u.GetGenerateName(), created))
+		}
+		u.SetUID(types.UID(fmt.Sprintf("%08d-0000-0000-0000-000000000000", created)))
+		return false, nil, nil
+	})
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	s, err := newServerWithClients(clientset, dynamicClient, client)
+	require.NoError(t, err)
+	s.timeout = 5 * time.Second
+	s.pollInterval = 10 * time.Millisecond
+	s.drainTimeout = 100 * time.Millisecond
+	s.shutdownTimeout = time.Second
+	s.missingResultTimeout = time.Second
+	return &testServer{
+		server:  s,
+		mr:      mr,
+		kube:    clientset,
+		dynamic: dynamicClient,
+	}
+}
+
+// Close releases the server's redis, if shutdown hasn't already
+func (ts *testServer) Close() {
+	if !ts.isDraining() {
+		close(ts.exit)
+		ts.pubsub.Close()
+		ts.redis.Close()
+	}
+	ts.mr.Close()
+}
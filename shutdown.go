@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var errShuttingDown = errors.New("operator is shutting down")
+
+// handoffError is returned by runExperiment when the operator
+// stopped waiting on a simulation before it finished. The
+// simulation is still reconciled by whichever replica holds the
+// lease, and its status can be read from /simulation on any
+// replica.
+type handoffError struct {
+	name string
+}
+
+func (e *handoffError) Error() string {
+	return fmt.Sprintf("operator shutting down, follow progress at /simulation?name=%s", e.name)
+}
+
+// beginRun registers an in-flight run. It returns false if the
+// server is draining and should not accept new work.
+func (s *server) beginRun() bool {
+	s.runsL.Lock()
+	defer s.runsL.Unlock()
+	if s.draining {
+		return false
+	}
+	s.runs.Add(1)
+	return true
+}
+
+func (s *server) isDraining() bool {
+	s.runsL.Lock()
+	defer s.runsL.Unlock()
+	return s.draining
+}
+
+// shutdown stops accepting new runs, waits for in-flight runs to
+// finish and hands off any that remain after drainTimeout. The
+// http server is shut down before pub/sub so that results posted
+// to this replica during the drain are still delivered.
+func (s *server) shutdown() error {
+	s.runsL.Lock()
+	s.draining = true
+	s.runsL.Unlock()
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Printf("All runs drained")
+	case <-time.After(s.drainTimeout):
+		log.Printf("Drain timed out after %v, handing off remaining runs", s.drainTimeout)
+		close(s.handoff)
+		<-done
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("http shutdown: %v", err)
+	}
+	close(s.exit)
+	if err := s.pubsub.Close(); err != nil {
+		return fmt.Errorf("pubsub: %v", err)
+	}
+	if err := s.redis.Close(); err != nil {
+		return fmt.Errorf("redis: %v", err)
+	}
+	log.Printf("Shutdown complete")
+	return nil
+}
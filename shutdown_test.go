@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func postRun(s *server, config *RunConfig) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(config)
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/run", bytes.NewReader(body)))
+	return w
+}
+
+func testRunConfig() *RunConfig {
+	return &RunConfig{PDBID: "1aki", Steps: 100, ChainID: "A"}
+}
+
+func TestRunRejectedWhileDraining(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	require.NoError(t, ts.shutdown())
+	w := postRun(ts.server, testRunConfig())
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, errShuttingDown.Error(), w.Body.String())
+	// Nothing was started
+	list, err := ts.simulations.List(metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, list.Items)
+}
+
+func TestShutdownHandsOffRuns(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- postRun(ts.server, testRunConfig())
+	}()
+	// Wait for the run to be in flight. Nothing reconciles
+	// the simulation, so it never finishes on its own.
+	require.Eventually(t, func() bool {
+		ts.requestsL.Lock()
+		defer ts.requestsL.Unlock()
+		return len(ts.requests) == 1
+	}, time.Second, 5*time.Millisecond)
+	list, err := ts.simulations.List(metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	name := list.Items[0].GetName()
+
+	require.NoError(t, ts.shutdown())
+	select {
+	case w := <-done:
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, (&handoffError{name: name}).Error(), w.Body.String())
+	default:
+		t.Fatal("shutdown returned before the run was handed off")
+	}
+}
+
+func TestShutdownDrainsRuns(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	ts.drainTimeout = 5 * time.Second
+	require.True(t, ts.beginRun())
+	finished := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(finished)
+		ts.runs.Done()
+	}()
+	require.NoError(t, ts.shutdown())
+	select {
+	case <-finished:
+	default:
+		t.Fatal("shutdown returned before the run finished")
+	}
+	// The run finished in time, so nothing was handed off
+	select {
+	case <-ts.handoff:
+		t.Fatal("handoff closed")
+	default:
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SimulationPhase is the lifecycle phase of a Simulation
+type SimulationPhase string
+
+const (
+	// SimulationPending has not had its pod created yet
+	SimulationPending SimulationPhase = "Pending"
+
+	// SimulationRunning has a pod that has not reported back
+	SimulationRunning SimulationPhase = "Running"
+
+	// SimulationSucceeded has a result available at ResultLocation,
+	// until the result expires
+	SimulationSucceeded SimulationPhase = "Succeeded"
+
+	// SimulationFailed ended with an error, see ErrorCategory
+	SimulationFailed SimulationPhase = "Failed"
+)
+
+// Simulation is the custom resource for a single run
+// of the simulation client.
+type Simulation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RunConfig        `json:"spec"`
+	Status SimulationStatus `json:"status,omitempty"`
+}
+
+// SimulationStatus ...
+type SimulationStatus struct {
+	Phase          SimulationPhase `json:"phase,omitempty"`
+	Backend        string          `json:"backend,omitempty"`
+	JobName        string          `json:"jobName,omitempty"`
+	PodName        string          `json:"podName,omitempty"`
+	CorrelationID  string          `json:"correlationID,omitempty"`
+	StartTime      *metav1.Time    `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time    `json:"completionTime,omitempty"`
+
+	// ResultLocation is where the result of a successful run can
+	// be downloaded. Results are only kept for a minute after they
+	// are reported, after which ResultLocation is cleared. The
+	// Simulation itself is deleted a day after it finishes.
+	ResultLocation string `json:"resultLocation,omitempty"`
+
+	ErrorCategory string `json:"errorCategory,omitempty"`
+	Message       string `json:"message,omitempty"`
+}
+
+// IsFinished returns true if the simulation will not
+// be reconciled any further.
+func (s *SimulationStatus) IsFinished() bool {
+	return s.Phase == SimulationSucceeded || s.Phase == SimulationFailed
+}
+
+var simulationGVK = schema.GroupVersionKind{
+	Group:   "foldy.io",
+	Version: "v1alpha1",
+	Kind:    "Simulation",
+}
+
+var simulationGVR = schema.GroupVersionResource{
+	Group:    simulationGVK.Group,
+	Version:  simulationGVK.Version,
+	Resource: "simulations",
+}
+
+func simulationFromUnstructured(u *unstructured.Unstructured) (*Simulation, error) {
+	sim := &Simulation{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(
+		u.UnstructuredContent(),
+		sim,
+	); err != nil {
+		return nil, fmt.Errorf("convert %s: %v", u.GetName(), err)
+	}
+	return sim, nil
+}
+
+func simulationToUnstructured(sim *Simulation) (*unstructured.Unstructured, error) {
+	sim.APIVersion, sim.Kind = simulationGVK.ToAPIVersionAndKind()
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(sim)
+	if err != nil {
+		return nil, fmt.Errorf("convert %s: %v", sim.Name, err)
+	}
+	return &unstructured.Unstructured{Object: obj}, nil
+}
+
+func (s *server) getSimulation(name string) (*Simulation, error) {
+	u, err := s.simulations.Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return simulationFromUnstructured(u)
+}
+
+func (s *server) createSimulation(config *RunConfig) (*Simulation, error) {
+	u, err := simulationToUnstructured(&Simulation{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-", config.PDBID),
+			Namespace:    s.namespace,
+		},
+		Spec: *config,
+	})
+	if err != nil {
+		return nil, err
+	}
+	u, err = s.simulations.Create(u, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return simulationFromUnstructured(u)
+}
+
+func (s *server) updateSimulationStatus(sim *Simulation) error {
+	u, err := simulationToUnstructured(sim)
+	if err != nil {
+		return err
+	}
+	_, err = s.simulations.UpdateStatus(u, metav1.UpdateOptions{})
+	return err
+}
+
+// errorCategories maps substrings of the messages reported
+// by the simulation client to a short category name.
+var errorCategories = []struct {
+	substr   string
+	category string
+}{
+	{"was not found in rtp entry", "bad_topology"},
+	{"entry in the topology database", "bad_topology"},
+	{"' not found", "pdb_not_found"},
+	{"not found in", "model_not_found"},
+	{"length of normalized chain", "chain_length"},
+	{"Incomplete ring", "incomplete_ring"},
+	{"water molecules can not be settled", "settle_water"},
+}
+
+func categorizeError(msg string) string {
+	for _, c := range errorCategories {
+		if strings.Contains(msg, c.substr) {
+			return c.category
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCategorizeError(t *testing.T) {
+	for msg, category := range map[string]string{
+		"pdb 'abcd' not found":                                 "pdb_not_found",
+		"model \"1\" not found in \"broken\", options are []":  "model_not_found",
+		"Atom OXT in residue ALA 1 was not found in rtp entry": "bad_topology",
+		"One or more water molecules can not be settled.":      "settle_water",
+		"something else entirely":                              "unknown",
+	} {
+		assert.Equal(t, category, categorizeError(msg), msg)
+	}
+}
+
+func TestSimulationUnstructured(t *testing.T) {
+	now := metav1.Now()
+	sim := &Simulation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "2l0e-abcde",
+			Namespace: "default",
+		},
+		Spec: RunConfig{
+			PDBID:   "2l0e",
+			Steps:   100,
+			ModelID: 1,
+			ChainID: "A",
+			Primary: "AKKKDNLLFGSIISAVDPVAVLAVFEEIHKKKA",
+			Mask:    "-+++++++++++++++++++++++++++++++-",
+			Seed:    -1,
+		},
+		Status: SimulationStatus{
+			Phase:     SimulationRunning,
+			PodName:   "foldy-sim-2l0e-01234567",
+			StartTime: &now,
+		},
+	}
+	u, err := simulationToUnstructured(sim)
+	require.NoError(t, err)
+	assert.Equal(t, "foldy.io/v1alpha1", u.GetAPIVersion())
+	assert.Equal(t, "Simulation", u.GetKind())
+	pdbID, _, _ := unstructured.NestedString(u.Object, "spec", "pdb_id")
+	assert.Equal(t, "2l0e", pdbID)
+	decoded, err := simulationFromUnstructured(u)
+	require.NoError(t, err)
+	assert.Equal(t, sim.Spec, decoded.Spec)
+	assert.Equal(t, sim.Status.Phase, decoded.Status.Phase)
+	assert.Equal(t, sim.Status.PodName, decoded.Status.PodName)
+	assert.False(t, decoded.Status.IsFinished())
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,18 +19,12 @@ import (
 	"time"
 
 	"github.com/fogleman/fauxgl"
-	"github.com/nfnt/resize"
 
 	"github.com/fogleman/ribbon/pdb"
-	"github.com/fogleman/ribbon/ribbon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/thavlik/foldy-operator/proteinnet"
-)
-
-const (
-	size  = 2048
-	scale = 4
+	"github.com/thavlik/foldy-operator/render"
 )
 
 func TestErrBrokenPDB(t *testing.T) {
@@ -226,17 +221,15 @@ func getBatchFromProteinNet(t *testing.T, r io.Reader) []*proteinnet.Record {
 		require.NoError(t, err)
 		batchSize = int(batchSizeV)
 	}
-	results := make(chan *proteinnet.Record)
-	stop := make(chan int)
-	defer func() {
-		stop <- 0
-		close(stop)
-	}()
-	proteinnet.ReadRecords(r, results, stop)
-	suites := make([]*proteinnet.Record, batchSize, batchSize)
-	for i := 0; i < batchSize; i++ {
-		suites[i] = <-results
+	// Tests need a PDB model and chain to run
+	scanner := proteinnet.NewScanner(context.Background(), r, proteinnet.ReadOptions{
+		Kinds: []proteinnet.IDKind{proteinnet.KindPDB},
+	})
+	suites := make([]*proteinnet.Record, 0, batchSize)
+	for len(suites) < batchSize && scanner.Next() {
+		suites = append(suites, scanner.Record())
 	}
+	require.NoError(t, scanner.Err())
 	return suites
 }
 
@@ -282,12 +275,25 @@ func TestCreateVideo(t *testing.T) {
 		chainID, ok = os.LookupEnv("CHAIN_ID")
 		require.True(t, ok)
 		chainID = trimQuotes(chainID)
-		primary, ok = os.LookupEnv("PRIMARY")
-		require.True(t, ok)
-		primary = trimQuotes(primary)
-		mask, ok = os.LookupEnv("MASK")
-		mask = trimQuotes(mask)
-		require.True(t, ok)
+		if proteinNetPath, ok := os.LookupEnv("PROTEINNET_PATH"); ok {
+			// Look up the chain instead of pasting its sequence
+			f, err := proteinnet.OpenIndexed(trimQuotes(proteinNetPath), proteinnet.ReadOptions{
+				Cheap: true,
+			})
+			require.NoError(t, err)
+			record, err := f.Lookup(pdbID, modelID, chainID)
+			f.Close()
+			require.NoError(t, err)
+			primary = record.Primary
+			mask = record.Mask
+		} else {
+			primary, ok = os.LookupEnv("PRIMARY")
+			require.True(t, ok)
+			primary = trimQuotes(primary)
+			mask, ok = os.LookupEnv("MASK")
+			mask = trimQuotes(mask)
+			require.True(t, ok)
+		}
 		log.Printf("Using custom PDB %v_%v_%v", pdbID, modelID, chainID)
 	} else {
 		// Default is 2l0e - a short chain with residues
@@ -322,8 +328,8 @@ func TestCreateVideo(t *testing.T) {
 
 	t.Run("should be deterministic", func(t *testing.T) {
 		require.NoError(t, os.Mkdir(fmt.Sprintf("/data/png/%s", suite.pdbID), 0644))
-		var context *fauxgl.Context
-		var camera *ribbon.Camera
+		renderer, err := render.NewRenderer(render.DefaultOptions())
+		require.NoError(t, err)
 		config, _ := json.Marshal(map[string]interface{}{
 			"pdb_id":   suite.pdbID,
 			"model_id": suite.modelID,
@@ -359,7 +365,6 @@ func TestCreateVideo(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, len(files), nsteps)
 		for i := 0; i < nsteps; i++ {
-			runtime.GC()
 			log.Printf("Rendering frame %d", i)
 			path := fmt.Sprintf("/data/tmp/%s_minim/%s_minim_%d.pdb", suite.pdbID, suite.pdbID, i)
 			f, err := os.Open(path)
@@ -386,12 +391,7 @@ func TestCreateVideo(t *testing.T) {
 			model := models[0]
 			assert.Equal(t, 1, len(model.Chains))
 			chain := model.Chains[0]
-			numKnownResidues := 0
-			for c := 0; c < len(suite.mask); c++ {
-				if suite.mask[c] == '+' {
-					numKnownResidues++
-				}
-			}
+			numKnownResidues := proteinnet.KnownCount(suite.mask)
 			numActual := 0
 			for _, residue := range chain.Residues {
 				if residue.ResName == "SOL" {
@@ -400,25 +400,8 @@ func TestCreateVideo(t *testing.T) {
 				numActual++
 			}
 			assert.Equal(t, numKnownResidues, numActual)
-			mesh := ribbon.ModelMesh(model)
-			if camera == nil {
-				m := mesh.BiUnitCube()
-				cam := ribbon.PositionCamera(model, m)
-				camera = &cam
-			} else {
-				mesh.BiUnitCube()
-			}
-			context = fauxgl.NewContext(int(size*scale*camera.Aspect), size*scale)
-			matrix := fauxgl.LookAt(camera.Eye, camera.Center, camera.Up).Perspective(camera.Fovy, camera.Aspect, 1, 100)
-			light := camera.Eye.Sub(camera.Center).Normalize()
-			shader := fauxgl.NewPhongShader(matrix, light, camera.Eye)
-			shader.AmbientColor = fauxgl.Gray(0.3)
-			shader.DiffuseColor = fauxgl.Gray(0.9)
-			context.Shader = shader
-			context.ClearColorBufferWith(fauxgl.HexColor("1D181F"))
-			context.DrawTriangles(mesh.Triangles)
-			image := context.Image()
-			image = resize.Resize(uint(size*camera.Aspect), size, image, resize.Bilinear)
+			image, err := renderer.Render(model)
+			require.NoError(t, err)
 			require.NoError(t, fauxgl.SavePNG(fmt.Sprintf("/data/png/%s/%s_%d.png", suite.pdbID, suite.pdbID, i), image))
 		}
 	})
@@ -505,12 +488,7 @@ func TestLangevinSeed(t *testing.T) {
 					model := models[0]
 					assert.Equal(t, 1, len(model.Chains))
 					chain := model.Chains[0]
-					numKnownResidues := 0
-					for c := 0; c < len(suite.mask); c++ {
-						if suite.mask[c] == '+' {
-							numKnownResidues++
-						}
-					}
+					numKnownResidues := proteinnet.KnownCount(suite.mask)
 					numActual := 0
 					for _, residue := range chain.Residues {
 						if residue.ResName == "SOL" {